@@ -0,0 +1,429 @@
+package sqlstruct
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scanners wrapping struct fields that need their column value converted
+// before it can be stored.
+
+// jsonScanner decodes a JSON column value in to dst, a pointer to the field.
+// A NULL value leaves the field unchanged.
+type jsonScanner struct {
+	dst interface{}
+}
+
+func (s *jsonScanner) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("sqlstruct: cannot decode JSON from %T", src)
+	}
+	return json.Unmarshal(data, s.dst)
+}
+
+// timeScanner stores a time column value in dst, a time.Time or *time.Time
+// field, parsing string values with layout. A NULL value leaves the field
+// unchanged.
+type timeScanner struct {
+	dst    reflect.Value
+	layout string
+}
+
+func (s *timeScanner) Scan(src interface{}) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		t = v
+	case []byte:
+		return s.Scan(string(v))
+	case string:
+		var err error
+		if t, err = time.Parse(s.layout, v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("sqlstruct: cannot convert %T to time.Time", src)
+	}
+
+	dst := s.dst
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	if dst.Type() != reflect.TypeOf(t) {
+		return fmt.Errorf("sqlstruct: time option on field of type %s", s.dst.Type())
+	}
+	dst.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// textScanner feeds a column value in its text form to dst, a field
+// implementing encoding.TextUnmarshaler. A NULL value leaves the field
+// unchanged.
+type textScanner struct {
+	dst encoding.TextUnmarshaler
+}
+
+func (s *textScanner) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return s.dst.UnmarshalText(v)
+	case string:
+		return s.dst.UnmarshalText([]byte(v))
+	default:
+		return s.dst.UnmarshalText([]byte(fmt.Sprint(v)))
+	}
+}
+
+// numericScanner stores a column value in dst, an integer or floating point
+// field or a pointer to one, parsing string and []byte values.
+type numericScanner struct {
+	dst reflect.Value
+}
+
+func newNumericScanner(dst reflect.Value) sql.Scanner {
+	return &numericScanner{dst}
+}
+
+// isNumeric reports whether t is an integer or floating point type scanned
+// as is by the driver, i.e. not implementing sql.Scanner or
+// encoding.TextUnmarshaler.
+func isNumeric(t reflect.Type) bool {
+	if !isNumericKind(t.Kind()) {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return !pt.Implements(scannerType) && !pt.Implements(textUnmarshalerType)
+}
+
+func (s *numericScanner) Scan(src interface{}) error {
+	dst, null := pointerDest(s.dst, src)
+	if null {
+		return nil
+	}
+	switch v := src.(type) {
+	case nil:
+		return fmt.Errorf("sqlstruct: cannot store NULL in %s", dst.Type())
+	case []byte:
+		return setString(dst, string(v))
+	case string:
+		return setString(dst, v)
+	default:
+		return setString(dst, fmt.Sprint(v))
+	}
+}
+
+// pointerDest returns the value a converter stores src in for dst, a field
+// of the type it converts to or a pointer to it. A pointer is set to nil if
+// src is NULL, which null reports, and otherwise allocated if nil and
+// dereferenced.
+func pointerDest(dst reflect.Value, src interface{}) (v reflect.Value, null bool) {
+	if dst.Kind() != reflect.Ptr {
+		return dst, false
+	}
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return dst, true
+	}
+	if dst.IsNil() {
+		dst.Set(reflect.New(dst.Type().Elem()))
+	}
+	return dst.Elem(), false
+}
+
+// boolScanner stores a column value in dst, a bool field or a pointer to
+// one, accepting the representations listed for Session.FlexibleBool.
+type boolScanner struct {
+	dst reflect.Value
+}
+
+func newBoolScanner(dst reflect.Value) sql.Scanner {
+	return &boolScanner{dst}
+}
+
+// isBool reports whether t is a boolean type scanned as is by the driver.
+func isBool(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t.Kind() == reflect.Bool && !pt.Implements(scannerType) && !pt.Implements(textUnmarshalerType)
+}
+
+func (s *boolScanner) Scan(src interface{}) error {
+	dst, null := pointerDest(s.dst, src)
+	if null {
+		return nil
+	}
+	var b bool
+	switch v := src.(type) {
+	case bool:
+		b = v
+	case int64:
+		if v != 0 && v != 1 {
+			return fmt.Errorf("sqlstruct: cannot convert %d to bool", v)
+		}
+		b = v == 1
+	case []byte:
+		return s.Scan(string(v))
+	case string:
+		switch strings.ToLower(v) {
+		case "1", "true", "t", "y":
+			b = true
+		case "0", "false", "f", "n":
+			b = false
+		default:
+			return fmt.Errorf("sqlstruct: cannot convert %q to bool", v)
+		}
+	default:
+		return fmt.Errorf("sqlstruct: cannot convert %T to bool", src)
+	}
+	dst.SetBool(b)
+	return nil
+}
+
+// trimScanner stores a column value in dst, a string field or a pointer to
+// one, without its trailing spaces, and its leading ones too if both is set.
+type trimScanner struct {
+	dst  reflect.Value
+	both bool
+}
+
+// isString reports whether t is a string type scanned as is by the driver.
+func isString(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t.Kind() == reflect.String && !pt.Implements(scannerType) && !pt.Implements(textUnmarshalerType)
+}
+
+func (s *trimScanner) Scan(src interface{}) error {
+	dst, null := pointerDest(s.dst, src)
+	if null {
+		return nil
+	}
+	var v string
+	switch src := src.(type) {
+	case nil:
+		return fmt.Errorf("sqlstruct: cannot store NULL in %s", dst.Type())
+	case string:
+		v = src
+	case []byte:
+		v = string(src)
+	default:
+		v = fmt.Sprint(src)
+	}
+	if s.both {
+		v = strings.TrimLeft(v, " ")
+	}
+	dst.SetString(strings.TrimRight(v, " "))
+	return nil
+}
+
+// hintScanner stores a column value in dst, an interface{} field, as the
+// type named by hint, one of the keys of hintTypes or "bytes" for []byte. A
+// NULL value sets the field to nil.
+type hintScanner struct {
+	dst  reflect.Value
+	hint string
+}
+
+// hintTypes maps the type hints of the "type" option to a constructor of the
+// sql.Null* type converting column values to them.
+var hintTypes = map[string]func() sql.Scanner{
+	"int64":   func() sql.Scanner { return new(sql.NullInt64) },
+	"float64": func() sql.Scanner { return new(sql.NullFloat64) },
+	"string":  func() sql.Scanner { return new(sql.NullString) },
+	"bool":    func() sql.Scanner { return new(sql.NullBool) },
+	"time":    func() sql.Scanner { return new(sql.NullTime) },
+}
+
+func (s *hintScanner) Scan(src interface{}) error {
+	if s.dst.Kind() != reflect.Interface {
+		return fmt.Errorf("sqlstruct: type option on field of type %s", s.dst.Type())
+	}
+	if src == nil {
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	}
+	var v interface{}
+	if s.hint == "bytes" {
+		switch src := src.(type) {
+		case []byte:
+			v = append([]byte(nil), src...)
+		case string:
+			v = []byte(src)
+		default:
+			return fmt.Errorf("sqlstruct: cannot convert %T to []byte", src)
+		}
+	} else {
+		newNull, ok := hintTypes[s.hint]
+		if !ok {
+			return fmt.Errorf("sqlstruct: unknown type hint %q", s.hint)
+		}
+		ns := newNull()
+		if err := ns.Scan(src); err != nil {
+			return err
+		}
+		// Every sql.Null* type stores the value in its first field.
+		v = reflect.ValueOf(ns).Elem().Field(0).Interface()
+	}
+	s.dst.Set(reflect.ValueOf(v))
+	return nil
+}
+
+// nullScanner scans a column in to dst, a pointer field, through the
+// matching sql.Null* type. A NULL value sets the field to nil, any other value
+// is stored in a newly allocated value.
+type nullScanner struct {
+	dst reflect.Value
+}
+
+// nullTypes maps the element types of the pointer fields handled by
+// nullScanner to a constructor of their sql.Null* intermediary.
+var nullTypes = map[reflect.Type]func() sql.Scanner{
+	reflect.TypeOf(""):          func() sql.Scanner { return new(sql.NullString) },
+	reflect.TypeOf(int64(0)):    func() sql.Scanner { return new(sql.NullInt64) },
+	reflect.TypeOf(int32(0)):    func() sql.Scanner { return new(sql.NullInt32) },
+	reflect.TypeOf(float64(0)):  func() sql.Scanner { return new(sql.NullFloat64) },
+	reflect.TypeOf(false):       func() sql.Scanner { return new(sql.NullBool) },
+	reflect.TypeOf(time.Time{}): func() sql.Scanner { return new(sql.NullTime) },
+}
+
+func (s *nullScanner) Scan(src interface{}) error {
+	if src == nil {
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	}
+	ns := nullTypes[s.dst.Type().Elem()]()
+	if err := ns.Scan(src); err != nil {
+		return err
+	}
+	// Every sql.Null* type stores the value in its first field.
+	v := reflect.New(s.dst.Type().Elem())
+	v.Elem().Set(reflect.ValueOf(ns).Elem().Field(0))
+	s.dst.Set(v)
+	return nil
+}
+
+// ptrScanner scans a column in to dst, a pointer field whose type implements
+// sql.Scanner, such as an embedded *sql.NullString. A NULL value sets the
+// field to nil, any other value is scanned in to a newly allocated value.
+type ptrScanner struct {
+	dst reflect.Value
+}
+
+func (s *ptrScanner) Scan(src interface{}) error {
+	if src == nil {
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	}
+	v := reflect.New(s.dst.Type().Elem())
+	if err := v.Interface().(sql.Scanner).Scan(src); err != nil {
+		return err
+	}
+	s.dst.Set(v)
+	return nil
+}
+
+// setString stores s, converted to the type of the field fv, in fv.
+func setString(fv reflect.Value, s string) error {
+	if sc, ok := fv.Addr().Interface().(sql.Scanner); ok {
+		return sc.Scan(s)
+	}
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setString(fv.Elem(), s)
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("cannot convert %q to %s", s, fv.Type())
+	}
+	return nil
+}
+
+// copyNullSources copies the value of each of fields of v tagged with the
+// nullsrc option in to the field of the same struct it names.
+func copyNullSources(v reflect.Value, fields []field) error {
+	for _, f := range fields {
+		target, ok := f.opts.get("nullsrc")
+		if !ok {
+			continue
+		}
+		src, ok := fieldValue(v, f.index)
+		if !ok {
+			continue
+		}
+		parent, _ := fieldValue(v, f.index[:len(f.index)-1])
+		if parent.Kind() == reflect.Ptr {
+			parent = parent.Elem()
+		}
+		dst := parent.FieldByName(target)
+		if !dst.IsValid() || !dst.CanSet() {
+			return fmt.Errorf("sqlstruct: nullsrc field %s not found in %s", target, parent.Type())
+		}
+		valuer, ok := src.Interface().(driver.Valuer)
+		if !ok {
+			return fmt.Errorf("sqlstruct: nullsrc field %s of type %s is not a driver.Valuer", f.fname, f.typ)
+		}
+		val, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+			continue
+		}
+		// Go converts integers to strings as code points, which is never
+		// what a numeric column means.
+		rv := reflect.ValueOf(val)
+		if !rv.Type().ConvertibleTo(dst.Type()) || dst.Kind() == reflect.String && isNumericKind(rv.Kind()) {
+			return fmt.Errorf("sqlstruct: cannot copy %s in to field %s of type %s", rv.Type(), target, dst.Type())
+		}
+		dst.Set(rv.Convert(dst.Type()))
+	}
+	return nil
+}
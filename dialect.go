@@ -0,0 +1,50 @@
+package sqlstruct
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the flavour of SQL generated by the package. The zero
+// Dialect uses "?" placeholders and quotes identifiers with double quotes.
+type Dialect int
+
+const (
+	MySQL    Dialect = iota + 1 // `ident` quoting, "?" placeholders
+	Postgres                    // "ident" quoting, "$1", "$2", ... placeholders
+	SQLite                      // "ident" quoting, "?" placeholders
+	Unquoted                    // no identifier quoting, "?" placeholders
+)
+
+// Placeholder returns the placeholder for the n-th (1-based) query argument.
+func (d Dialect) Placeholder(n int) string {
+	if d == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// Quote quotes the SQL identifier ident, doubling any quote characters
+// within it.
+func (d Dialect) Quote(ident string) string {
+	var q string
+	switch d {
+	case Unquoted:
+		return ident
+	case MySQL:
+		q = "`"
+	default:
+		q = `"`
+	}
+	return q + strings.Replace(ident, q, q+q, -1) + q
+}
+
+// PlaceholdersN returns a comma-separated list of n placeholders in the style
+// of dialect, e.g. "?, ?" for MySQL or "$1, $2" for Postgres.
+func PlaceholdersN(n int, dialect Dialect) string {
+	ph := make([]string, n)
+	for i := range ph {
+		ph[i] = dialect.Placeholder(i + 1)
+	}
+	return strings.Join(ph, ", ")
+}
@@ -0,0 +1,50 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlstruct
+
+import "fmt"
+
+// ScanOne scans the next row from rows in to a new value of the struct type T
+// and returns it. See Scan.
+func ScanOne[T any](rows Rows) (T, error) {
+	var t T
+	err := Scan(&t, rows)
+	return t, err
+}
+
+// ScanSlice scans all remaining rows in to a new slice of T, which must be a
+// struct type or a pointer to one. See ScanAll.
+func ScanSlice[T any](rows Iterator) ([]T, error) {
+	var ts []T
+	if err := ScanAll(&ts, rows); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// ScanColumn scans the single column of every remaining row of rows in to a
+// new slice of T, e.g. the ids returned by "SELECT id FROM users". It fails
+// if rows has more than one column. The error returned by rows.Err is
+// returned once iteration is done.
+func ScanColumn[T any](rows Iterator) ([]T, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) != 1 {
+		return nil, fmt.Errorf("sqlstruct: expected a single column; got %d", len(cols))
+	}
+	var ts []T
+	for rows.Next() {
+		var t T
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
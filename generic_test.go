@@ -0,0 +1,75 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlstruct
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestScanOne(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_c", "c")
+
+	r, err := ScanOne[testType](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testType{"a", "", "c"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	if _, err := ScanOne[string](rows); !errors.Is(err, ErrInvalidDest) {
+		t.Errorf("expected ErrInvalidDest got %v", err)
+	}
+}
+
+func TestScanSlice(t *testing.T) {
+	rows := &testResult{
+		columns: []string{"field_a", "field_c"},
+		rows: [][]interface{}{
+			{"a1", "c1"},
+			{"a2", "c2"},
+		},
+	}
+
+	r, err := ScanSlice[testType](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := []testType{{"a1", "", "c1"}, {"a2", "", "c2"}}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	rows.pos = 0
+	rp, err := ScanSlice[*testType](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rp) != 2 || *rp[1] != e[1] {
+		t.Errorf("unexpected result %v", rp)
+	}
+}
+
+func TestScanColumn(t *testing.T) {
+	rows := &testResult{
+		columns: []string{"name"},
+		rows:    [][]interface{}{{"a"}, {"b"}},
+	}
+	r, err := ScanColumn[string](rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := []string{"a", "b"}; !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	rows = &testResult{columns: []string{"id", "name"}}
+	if _, err := ScanColumn[string](rows); err == nil {
+		t.Errorf("expected error for multiple columns")
+	}
+}
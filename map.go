@@ -0,0 +1,55 @@
+package sqlstruct
+
+// ScanMap scans the next row from rows in to a new map keyed by column name,
+// for queries whose shape is not known at compile time. Values are returned
+// as the driver provides them, e.g. int64, float64 or time.Time, except that
+// []byte values are converted to string.
+func ScanMap(rows Rows) (map[string]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	return scanMap(rows, cols)
+}
+
+// ScanMapSlice scans every remaining row of rows in to a new map keyed by
+// column name, like ScanMap, and returns rows.Err. Each row gets a map of its
+// own so callers may retain them. rows is not closed.
+func ScanMapSlice(rows Iterator) ([]map[string]interface{}, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var maps []map[string]interface{}
+	for rows.Next() {
+		m, err := scanMap(rows, cols)
+		if err != nil {
+			return maps, err
+		}
+		maps = append(maps, m)
+	}
+	return maps, rows.Err()
+}
+
+// scanMap scans the current row of rows, whose columns are cols, in to a new
+// map.
+func scanMap(rows Rows, cols []string) (map[string]interface{}, error) {
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, len(cols))
+	for i, name := range cols {
+		if b, ok := vals[i].([]byte); ok {
+			m[name] = string(b)
+		} else {
+			m[name] = vals[i]
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,48 @@
+package sqlstruct
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Name mappers for Session.NameMapper, deriving column names from the names
+// of untagged struct fields.
+
+// Identity returns name unchanged.
+func Identity(name string) string {
+	return name
+}
+
+// SnakeCase converts a Go field name to snake case, keeping initialisms
+// together, e.g. "CreatedAt" to "created_at" and "UserID" to "user_id".
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// CamelCase converts a Go field name to lower camel case, lowering a leading
+// initialism, e.g. "CreatedAt" to "createdAt" and "ID" to "id".
+func CamelCase(name string) string {
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsUpper(r) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(r)
+	}
+	return string(runes)
+}
@@ -0,0 +1,234 @@
+package sqlstruct
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// MemRows is an in-memory result set, for driving Scan, ScanAll and the
+// other scanning functions without a database, e.g. in tests and examples:
+//
+//	rows := sqlstruct.NewMemRows([]string{"id", "name"}, [][]interface{}{
+//		{int64(1), "alice"},
+//		{int64(2), "bob"},
+//	})
+//	var users []User
+//	err := sqlstruct.ScanAll(&users, rows)
+//
+// Like sql.Rows, it must be advanced with Next before each row is scanned,
+// and a row may be scanned repeatedly. Values are converted to the scan
+// destinations much like database/sql does: sql.Scanner destinations are
+// passed the value as is, values are assigned to destinations of a
+// compatible type, numbers are converted between numeric types if they fit
+// exactly, strings and []byte are parsed in to numbers and booleans, and in
+// to time.Time values in the time.RFC3339Nano format, and nil is stored as
+// the zero value of pointer, slice, map and interface destinations.
+type MemRows struct {
+	columns []string
+	data    [][]interface{}
+	pos     int
+	closed  bool
+}
+
+// NewMemRows returns a result set with the columns named columns and a row
+// per element of data, each holding a value per column.
+func NewMemRows(columns []string, data [][]interface{}) *MemRows {
+	return &MemRows{columns: columns, data: data}
+}
+
+// Columns returns the names of the columns.
+func (r *MemRows) Columns() ([]string, error) {
+	if r.closed {
+		return nil, errMemRowsClosed
+	}
+	return r.columns, nil
+}
+
+// Next advances to the next row, reporting false if there is none.
+func (r *MemRows) Next() bool {
+	if r.closed || r.pos >= len(r.data) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+// Scan copies the values of the current row in to dest, which must hold a
+// destination per column.
+func (r *MemRows) Scan(dest ...interface{}) error {
+	if r.closed {
+		return errMemRowsClosed
+	}
+	if r.pos == 0 {
+		return errors.New("sqlstruct: Scan called without calling Next")
+	}
+	row := r.data[r.pos-1]
+	if len(row) != len(r.columns) {
+		return fmt.Errorf("sqlstruct: row %d has %d values for %d columns", r.pos, len(row), len(r.columns))
+	}
+	if len(dest) != len(r.columns) {
+		return fmt.Errorf("sqlstruct: expected %d destination arguments in Scan, not %d", len(r.columns), len(dest))
+	}
+	for i, d := range dest {
+		if err := memAssign(d, row[i]); err != nil {
+			return fmt.Errorf("sqlstruct: Scan error on column index %d, name %q: %w", i, r.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// Err always returns nil.
+func (r *MemRows) Err() error {
+	return nil
+}
+
+// Close closes the rows, after which Next reports false.
+func (r *MemRows) Close() error {
+	r.closed = true
+	return nil
+}
+
+var errMemRowsClosed = errors.New("sqlstruct: rows are closed")
+
+// ScanRawRow reads the current row of rows as raw bytes, along with the
+// column names, so that it can be interpreted later, possibly several times,
+// with UnmarshalRaw, e.g. in to a struct type chosen from a discriminator
+// column. The values are copied and remain valid after rows is advanced.
+// NULL values are nil; values that drivers do not return as text are
+// formatted as database/sql does, times in the time.RFC3339Nano format that
+// UnmarshalRaw parses back in to time.Time fields.
+func ScanRawRow(rows Rows) ([]sql.RawBytes, []string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]interface{}, len(cols))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	if err := rows.Scan(values...); err != nil {
+		return nil, nil, err
+	}
+	raw := make([]sql.RawBytes, len(cols))
+	for i, v := range values {
+		switch v := (*v.(*interface{})).(type) {
+		case nil:
+		case []byte:
+			raw[i] = append(sql.RawBytes(nil), v...)
+		case string:
+			raw[i] = sql.RawBytes(v)
+		case time.Time:
+			raw[i] = sql.RawBytes(v.Format(time.RFC3339Nano))
+		default:
+			raw[i] = sql.RawBytes(fmt.Sprint(v))
+		}
+	}
+	return raw, cols, nil
+}
+
+// UnmarshalRaw scans a row read by ScanRawRow, with the columns cols and the
+// values raw, in to the struct pointed to by dest, as Scan does. Values are
+// converted from their text as described for MemRows.
+func (s *Session) UnmarshalRaw(dest interface{}, cols []string, raw []sql.RawBytes) error {
+	if len(raw) != len(cols) {
+		return fmt.Errorf("sqlstruct: %d raw values for %d columns", len(raw), len(cols))
+	}
+	row := make([]interface{}, len(raw))
+	for i, b := range raw {
+		if b != nil {
+			row[i] = []byte(b)
+		}
+	}
+	rows := NewMemRows(cols, [][]interface{}{row})
+	rows.Next()
+	return s.Scan(dest, rows)
+}
+
+// UnmarshalRaw scans a row read by ScanRawRow in to dest. See
+// Session.UnmarshalRaw.
+func UnmarshalRaw(dest interface{}, cols []string, raw []sql.RawBytes) error {
+	return std.UnmarshalRaw(dest, cols, raw)
+}
+
+// memAssign stores src in the destination dest.
+func memAssign(dest, src interface{}) error {
+	if sc, ok := dest.(sql.Scanner); ok {
+		return sc.Scan(src)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination not a pointer: %T", dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot store NULL in %s", dv.Type())
+	}
+	if b, ok := src.([]byte); ok {
+		// Do not alias the caller's data.
+		src = append([]byte(nil), b...)
+	}
+	sv := reflect.ValueOf(src)
+	switch {
+	case sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+	case isNumericKind(sv.Kind()) && isNumericKind(dv.Kind()):
+		// Go through the text form, so that values out of range or with a
+		// fraction for an integer fail instead of being truncated.
+		return setString(dv, formatNumber(sv))
+	case dv.Type() == timeType && (sv.Kind() == reflect.String || sv.Type() == reflect.TypeOf([]byte(nil))):
+		t, err := time.Parse(time.RFC3339Nano, fmt.Sprintf("%s", src))
+		if err != nil {
+			return err
+		}
+		dv.Set(reflect.ValueOf(t))
+	case sv.Kind() == reflect.String && dv.Type() == reflect.TypeOf([]byte(nil)):
+		dv.SetBytes([]byte(sv.String()))
+	case sv.Kind() == reflect.String:
+		return setString(dv, sv.String())
+	case sv.Type() == reflect.TypeOf([]byte(nil)):
+		return setString(dv, string(sv.Bytes()))
+	case dv.Kind() == reflect.String:
+		dv.SetString(fmt.Sprint(src))
+	case dv.Kind() == reflect.Ptr:
+		p := reflect.New(dv.Type().Elem())
+		if err := memAssign(p.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(p)
+	default:
+		return fmt.Errorf("cannot convert %T to %s", src, dv.Type())
+	}
+	return nil
+}
+
+// formatNumber returns the text form of v, an integer or floating point
+// value.
+func formatNumber(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+}
+
+// isNumericKind reports whether k is an integer or floating point kind.
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package sqlstruct
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMemType struct {
+	ID    int64   `sql:"id"`
+	Name  string  `sql:"name"`
+	Score float64 `sql:"score"`
+	Nick  *string `sql:"nick"`
+}
+
+func TestMemRows(t *testing.T) {
+	rows := NewMemRows([]string{"id", "name", "score", "nick"}, [][]interface{}{
+		{int64(1), "alice", "2.5", nil},
+		{2, []byte("bob"), int64(3), "b"},
+	})
+	var r []testMemType
+	if err := ScanAll(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	nick := "b"
+	e := []testMemType{{1, "alice", 2.5, nil}, {2, "bob", 3, &nick}}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+func TestMemRowsErrors(t *testing.T) {
+	rows := NewMemRows([]string{"id"}, [][]interface{}{{"x"}})
+	var r testMemType
+	if err := Scan(&r, rows); err == nil {
+		t.Errorf("expected error scanning before Next")
+	}
+	rows.Next()
+	if err := Scan(&r, rows); err == nil {
+		t.Errorf("expected error converting %q to int64", "x")
+	}
+	rows.Close()
+	if rows.Next() {
+		t.Errorf("expected no row after Close")
+	}
+}
+
+type testJoinUser struct {
+	ID   int64  `sql:"id"`
+	Name string `sql:"name"`
+}
+
+func (testJoinUser) TableName() string { return "users" }
+
+type testJoinOrder struct {
+	ID     int64 `sql:"id"`
+	UserID int64 `sql:"user_id"`
+}
+
+func (testJoinOrder) TableName() string { return "orders" }
+
+func TestJoin(t *testing.T) {
+	s := NewSession()
+	e := `"users"."ID" as "users.id", "users"."Name" as "users.name", "orders"."ID" as "orders.id", "orders"."UserID" as "orders.user_id"`
+	if c := s.Join(testJoinUser{}, &testJoinOrder{}); c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c := s.Join(testJoinUser{}, 1); c != "" {
+		t.Errorf("expected empty list for a non-struct, got %q", c)
+	}
+
+	rows := NewMemRows([]string{"users.id", "users.name", "orders.id", "orders.user_id"}, [][]interface{}{
+		{int64(1), "alice", int64(10), int64(1)},
+		{int64(1), "alice", int64(11), int64(1)},
+	})
+	var orders []testJoinOrder
+	for rows.Next() {
+		var u testJoinUser
+		var o testJoinOrder
+		if err := s.ScanJoin(rows, &o, &u); err != nil {
+			t.Fatal(err)
+		}
+		if u != (testJoinUser{1, "alice"}) {
+			t.Errorf("unexpected user %+v", u)
+		}
+		orders = append(orders, o)
+	}
+	if e := []testJoinOrder{{10, 1}, {11, 1}}; !reflect.DeepEqual(orders, e) {
+		t.Errorf("expected %+v got %+v", e, orders)
+	}
+}
+
+func TestJoinColumnNameTransform(t *testing.T) {
+	s := NewSession()
+	s.ColumnNameTransform = SnakeCase
+	s.MatchTransformedNames = true
+	e := `"testGoTagType"."UserID" as "testGoTagType.user_id", "testGoTagType"."FullName" as "testGoTagType.full_name"`
+	list := s.Join(testGoTagType{})
+	if list != e {
+		t.Fatalf("expected %q got %q", e, list)
+	}
+
+	// Scan the result columns named by the aliases of the select list back.
+	var cols []string
+	for _, c := range strings.Split(list, ", ") {
+		cols = append(cols, strings.Trim(c[strings.Index(c, " as ")+4:], `"`))
+	}
+	rows := NewMemRows(cols, [][]interface{}{{int64(1), "n"}})
+	var r testGoTagType
+	for rows.Next() {
+		if err := s.ScanJoin(rows, &r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r != (testGoTagType{1, "n"}) {
+		t.Errorf("unexpected %+v", r)
+	}
+}
+
+type testRawCircle struct {
+	Kind   string  `sql:"kind"`
+	Radius float64 `sql:"size"`
+}
+
+type testRawSquare struct {
+	Kind    string    `sql:"kind"`
+	Side    int64     `sql:"size"`
+	Created time.Time `sql:"created"`
+}
+
+func TestScanRawRow(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	rows := NewMemRows([]string{"kind", "size", "note", "created"}, [][]interface{}{
+		{"square", int64(3), nil, created},
+	})
+	rows.Next()
+	raw, cols, err := ScanRawRow(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := []sql.RawBytes{sql.RawBytes("square"), sql.RawBytes("3"), nil, sql.RawBytes("2020-01-02T03:04:05.000000006Z")}; !reflect.DeepEqual(raw, e) {
+		t.Errorf("expected %q got %q", e, raw)
+	}
+
+	var c testRawCircle
+	if err := UnmarshalRaw(&c, cols, raw); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testRawCircle{"square", 3}); c != e {
+		t.Errorf("expected %+v got %+v", e, c)
+	}
+	var sq testRawSquare
+	if err := UnmarshalRaw(&sq, cols, raw); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testRawSquare{"square", 3, created}); sq != e {
+		t.Errorf("expected %+v got %+v", e, sq)
+	}
+
+	if err := UnmarshalRaw(&sq, cols, raw[:1]); err == nil {
+		t.Errorf("expected error for missing values")
+	}
+}
+
+func TestMemRowsNumericConversion(t *testing.T) {
+	var i8 int8
+	var n int
+	var f float64
+	for _, c := range []struct {
+		src  interface{}
+		dest interface{}
+		ok   bool
+	}{
+		{int64(100), &i8, true},
+		{int64(300), &i8, false},
+		{1.9, &n, false},
+		{2.0, &n, true},
+		{int64(-1), new(uint), false},
+		{int64(3), &f, true},
+	} {
+		rows := NewMemRows([]string{"v"}, [][]interface{}{{c.src}})
+		rows.Next()
+		if err := rows.Scan(c.dest); (err == nil) != c.ok {
+			t.Errorf("scanning %v (%T) in to %T: unexpected error %v", c.src, c.src, c.dest, err)
+		}
+	}
+	if i8 != 100 || n != 2 || f != 3 {
+		t.Errorf("unexpected values %d, %d, %g", i8, n, f)
+	}
+}
@@ -0,0 +1,57 @@
+package sqlstruct
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Merge copies the non-zero fields of src in to the fields of dst mapped to
+// the same column, leaving the other fields of dst unchanged, e.g. to combine
+// the partial results of overlapping queries. dst must be a pointer to a
+// struct and src a struct or pointer to struct, possibly of a different type.
+// Fields are matched by column name, not Go field name, and following the
+// session's CaseInsensitive. It returns an error if the types of two matched
+// fields differ and src's cannot be assigned to dst's; fields copied before
+// then are kept.
+func (s *Session) Merge(dst, src interface{}) error {
+	dsttyp, err := structPtrType(dst)
+	if err != nil {
+		return err
+	}
+	srcv, err := structValue(src)
+	if err != nil {
+		return err
+	}
+	dstv := reflect.ValueOf(dst).Elem()
+	names := s.info(dsttyp).names
+	if s.CaseInsensitive {
+		names = s.info(dsttyp).folded
+	}
+	for _, f := range s.fields(srcv.Type()) {
+		key := f.name
+		if s.CaseInsensitive {
+			key = strings.ToLower(key)
+		}
+		df, ok := names[key]
+		if !ok {
+			continue
+		}
+		fv, ok := fieldValue(srcv, f.index)
+		if !ok || fv.IsZero() {
+			continue
+		}
+		dfv := fieldByIndex(dstv, df.index)
+		if !fv.Type().AssignableTo(dfv.Type()) {
+			return fmt.Errorf("sqlstruct: cannot merge column %s of type %s in to field %s of type %s", f.name, fv.Type(), df.fname, dfv.Type())
+		}
+		dfv.Set(fv)
+	}
+	return nil
+}
+
+// Merge copies the non-zero fields of src in to the fields of dst mapped to
+// the same column. See Session.Merge.
+func Merge(dst, src interface{}) error {
+	return std.Merge(dst, src)
+}
@@ -0,0 +1,45 @@
+package sqlstruct
+
+import "testing"
+
+type testMergeSummary struct {
+	ID    string `sql:"id"`
+	Name  string `sql:"name"`
+	Score int    `sql:"score"`
+}
+
+type testMergeDetail struct {
+	Ident string `sql:"id"`
+	Title string `sql:"name"`
+	Email string `sql:"email"`
+}
+
+func TestMerge(t *testing.T) {
+	dst := testMergeSummary{ID: "1", Name: "old", Score: 3}
+	if err := Merge(&dst, testMergeDetail{Title: "new", Email: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testMergeSummary{ID: "1", Name: "new", Score: 3}); dst != e {
+		t.Errorf("expected %+v got %+v", e, dst)
+	}
+
+	if err := Merge(&dst, &testMergeSummary{Score: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testMergeSummary{ID: "1", Name: "new", Score: 5}); dst != e {
+		t.Errorf("expected %+v got %+v", e, dst)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	var dst testMergeSummary
+	if err := Merge(dst, testMergeDetail{}); err == nil {
+		t.Errorf("expected error for a non-pointer dst")
+	}
+	src := struct {
+		Score string `sql:"score"`
+	}{"x"}
+	if err := Merge(&dst, src); err == nil {
+		t.Errorf("expected error merging a string in to an int")
+	}
+}
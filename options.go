@@ -0,0 +1,49 @@
+package sqlstruct
+
+// A ScanOption overrides a setting of the session for a single scan call:
+//
+//	err := s.Scan(&dest, rows, sqlstruct.Strict(), sqlstruct.ResetFields())
+//
+// Options are applied in order, so a later option wins over an earlier one
+// setting the same thing. They never modify the session.
+type ScanOption func(*scanConfig)
+
+// scanConfig holds the settings of a single scan call.
+type scanConfig struct {
+	strict bool   // unmapped and duplicate columns are an error
+	reset  bool   // zero the mapped fields of dest before scanning
+	logger Logger // receives notices, never nil
+}
+
+// Strict makes unmapped and duplicate columns an error, as if the session
+// were Strict.
+func Strict() ScanOption {
+	return func(c *scanConfig) { c.strict = true }
+}
+
+// ResetFields sets every field of dest mapped to a column to its zero value
+// before scanning, as ScanReset does. It has no effect on scans allocating
+// fresh elements, such as ScanAll.
+func ResetFields() ScanOption {
+	return func(c *scanConfig) { c.reset = true }
+}
+
+// WithLogger reports the notices of the call to l instead of the session's
+// logger. A nil l discards them.
+func WithLogger(l Logger) ScanOption {
+	return func(c *scanConfig) {
+		if l == nil {
+			l = nopLogger{}
+		}
+		c.logger = l
+	}
+}
+
+// config returns the settings of a scan call made with opts.
+func (s *Session) config(opts []ScanOption) scanConfig {
+	c := scanConfig{strict: s.Strict, logger: s.logger()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
@@ -0,0 +1,118 @@
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+)
+
+// Queryer is the interface of the database handles Query runs its query
+// against. It is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Query runs query with args on db and scans all resulting rows in to the
+// slice pointed to by dest, as with ScanAll. Scanning stops with ctx's error
+// if ctx is cancelled between rows. The rows are always closed.
+func (s *Session) Query(ctx context.Context, db Queryer, dest interface{}, query string, args ...interface{}) (err error) {
+	valtyp, err := sliceStructType(dest)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if err := s.scanRows(ctx, reflect.ValueOf(dest), s.info(valtyp), rows, s.config(nil)); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// Get runs query with args on db and scans the first resulting row in to the
+// struct pointed to by dest, as with Scan. If the query returned no rows,
+// sql.ErrNoRows is returned. The rows are always closed.
+func (s *Session) Get(ctx context.Context, db Queryer, dest interface{}, query string, args ...interface{}) (err error) {
+	if _, err := structPtrType(dest); err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return s.Scan(dest, rows)
+}
+
+// WithTx begins a transaction on db and runs fn with it. The transaction is
+// committed if fn returns nil and rolled back otherwise, in which case fn's
+// error is returned. If fn panics the transaction is rolled back and the
+// panic propagated. The session's Query, Get and scanning helpers may be used
+// with tx inside fn.
+func (s *Session) WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// row adapts a *sql.Row, which hides its columns, to the Rows interface.
+type row struct {
+	*sql.Row
+	cols []string
+}
+
+func (r row) Columns() ([]string, error) {
+	return r.cols, nil
+}
+
+// ScanRow scans r, as returned by QueryRow, in to the struct pointed to by
+// dest. Since sql.Row does not expose its columns, the caller supplies them
+// in the order the query selects them, e.g. the names passed to the SELECT.
+// If the query returned no rows, sql.ErrNoRows is returned.
+func (s *Session) ScanRow(dest interface{}, r *sql.Row, columns []string) error {
+	return s.Scan(dest, row{r, columns})
+}
+
+// ScanRow scans r, as returned by QueryRow, in to the struct pointed to by
+// dest. See Session.ScanRow.
+func ScanRow(dest interface{}, r *sql.Row, columns []string) error {
+	return std.ScanRow(dest, r, columns)
+}
+
+// WithTx runs fn in a transaction on db. See Session.WithTx.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return std.WithTx(ctx, db, fn)
+}
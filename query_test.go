@@ -0,0 +1,202 @@
+package sqlstruct
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// testDriver is a database/sql driver whose queries return canned results:
+// the query text names an entry of testFixtures.
+type testDriver struct{}
+
+type testFixture struct {
+	columns []string
+	rows    [][]driver.Value
+	closed  int32 // number of result sets closed
+}
+
+var testFixtures = map[string]*testFixture{
+	"types": {
+		columns: []string{"field_a", "field_b", "field_c"},
+		rows: [][]driver.Value{
+			{"a1", "b1", "c1"},
+			{"a2", "b2", "c2"},
+		},
+	},
+	"empty": {
+		columns: []string{"field_a", "field_b", "field_c"},
+	},
+}
+
+func init() {
+	sql.Register("sqlstruct_test", testDriver{})
+}
+
+func (testDriver) Open(name string) (driver.Conn, error) {
+	return testConn{}, nil
+}
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) {
+	f, ok := testFixtures[query]
+	if !ok {
+		return nil, errors.New("unknown fixture " + query)
+	}
+	return testStmt{f}, nil
+}
+
+func (testConn) Close() error              { return nil }
+func (testConn) Begin() (driver.Tx, error) { return testTx{}, nil }
+
+type testTx struct{}
+
+// Number of transactions committed and rolled back.
+var testCommits, testRollbacks int32
+
+func (testTx) Commit() error {
+	atomic.AddInt32(&testCommits, 1)
+	return nil
+}
+
+func (testTx) Rollback() error {
+	atomic.AddInt32(&testRollbacks, 1)
+	return nil
+}
+
+type testStmt struct {
+	f *testFixture
+}
+
+func (testStmt) Close() error  { return nil }
+func (testStmt) NumInput() int { return -1 }
+
+func (testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &testDriverRows{f: s.f}, nil
+}
+
+type testDriverRows struct {
+	f   *testFixture
+	pos int
+}
+
+func (r *testDriverRows) Columns() []string { return r.f.columns }
+
+func (r *testDriverRows) Close() error {
+	atomic.AddInt32(&r.f.closed, 1)
+	return nil
+}
+
+func (r *testDriverRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.f.rows) {
+		return io.EOF
+	}
+	copy(dest, r.f.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlstruct_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQuery(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	f := testFixtures["types"]
+	closed := atomic.LoadInt32(&f.closed)
+
+	var r []testType
+	if err := NewSession().Query(context.Background(), db, &r, "types"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := []testType{{"a1", "", "c1"}, {"a2", "", "c2"}}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+	if atomic.LoadInt32(&f.closed) == closed {
+		t.Errorf("rows were not closed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewSession().Query(ctx, db, &r, "types"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+}
+
+func TestScanRow(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	var r testType
+	cols := []string{"field_a", "field_b", "field_c"}
+	if err := ScanRow(&r, db.QueryRow("types"), cols); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testType{"a1", "", "c1"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	if err := ScanRow(&r, db.QueryRow("empty"), cols); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows got %v", err)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	s := NewSession()
+
+	commits := atomic.LoadInt32(&testCommits)
+	var r []testType
+	err := s.WithTx(ctx, db, func(tx *sql.Tx) error {
+		return s.Query(ctx, tx, &r, "types")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(r) != 2 {
+		t.Errorf("expected 2 rows got %d", len(r))
+	}
+	if atomic.LoadInt32(&testCommits) != commits+1 {
+		t.Errorf("transaction was not committed")
+	}
+
+	rollbacks := atomic.LoadInt32(&testRollbacks)
+	failed := errors.New("failed")
+	if err := s.WithTx(ctx, db, func(tx *sql.Tx) error { return failed }); err != failed {
+		t.Errorf("expected fn's error got %v", err)
+	}
+	if atomic.LoadInt32(&testRollbacks) != rollbacks+1 {
+		t.Errorf("transaction was not rolled back on error")
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("expected panic to propagate got %v", p)
+			}
+		}()
+		s.WithTx(ctx, db, func(tx *sql.Tx) error { panic("boom") })
+	}()
+	if atomic.LoadInt32(&testRollbacks) != rollbacks+2 {
+		t.Errorf("transaction was not rolled back on panic")
+	}
+}
@@ -16,7 +16,7 @@ For example:
 		F2 string `sql:"f2"`
 	}
 
-	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM tablename", sqlstruct.Columns(T)))
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM tablename", sqlstruct.ColumnsString(T{})))
 	...
 
 	for rows.Next() {
@@ -32,14 +32,98 @@ For example:
 package sqlstruct
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 )
 
 // Modified version of sqlstruct (http://go.pkgdoc.org/github.com/kisielk/sqlstruct)
 // Added support for anonymous fields/structs
 
+// ErrInvalidDest is returned when a value passed to the package is not of a
+// type the called function supports, e.g. a non-pointer dest for Scan.
+var ErrInvalidDest = errors.New("sqlstruct: invalid destination")
+
+// ErrNoPrimaryKey is returned by PrimaryKey if no field is tagged "pk".
+var ErrNoPrimaryKey = errors.New("sqlstruct: no primary key field")
+
+// ErrUnmappedColumn is wrapped by the errors returned by strict scans when
+// result columns are not mapped to any field.
+var ErrUnmappedColumn = errors.New("sqlstruct: unmapped column")
+
+// ErrDuplicateColumn is wrapped by the errors returned by strict scans when a
+// result column appears more than once.
+var ErrDuplicateColumn = errors.New("sqlstruct: duplicate column")
+
+// ScanError is returned when the value of a result column cannot be stored
+// in the field it is mapped to, e.g. because of a type mismatch. It wraps the
+// error of the driver or of the field's sql.Scanner.
+type ScanError struct {
+	Column string       // name of the result column
+	Field  string       // name of the Go field
+	Type   reflect.Type // type of the Go field
+	Err    error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("sqlstruct: scanning column %q in to field %s (%s): %v", e.Column, e.Field, e.Type, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// columnError is an error about result columns wrapping the kind of error,
+// such as ErrUnmappedColumn, without repeating its text.
+type columnError struct {
+	kind error
+	msg  string
+}
+
+func (e *columnError) Error() string {
+	return e.msg
+}
+
+func (e *columnError) Unwrap() error {
+	return e.kind
+}
+
+// ErrFieldAccess is wrapped by the errors returned when a field cannot be
+// bound to its column because reflection panicked, e.g. in a converter. The
+// error names the field and its index path.
+var ErrFieldAccess = errors.New("sqlstruct: cannot access field")
+
+// Logger is the interface used to report notices, such as result columns that
+// are not mapped to any struct field. It is implemented by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+var logger Logger = nopLogger{}
+
+// SetLogger sets the logger used by sessions that have no Logger of their own,
+// including the one behind the package-level functions. By default notices
+// are discarded; passing nil restores that behaviour.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	logger = l
+}
+
 // Rows defines the interface of types that are scannable with the Scan function.
 // It is implemented by the sql.Rows type from the standard library
 type Rows interface {
@@ -47,117 +131,1692 @@ type Rows interface {
 	Columns() ([]string, error)
 }
 
+// Session maintains a type info cache. It is safe for concurrent use by
+// multiple goroutines.
 type Session struct {
-	finfos map[reflect.Type][]field
+	// Logger overrides the package-level logger for this session if non-nil.
+	Logger Logger
+
+	// Strict makes scanning fail if a result column is not mapped to any
+	// struct field, instead of discarding it.
+	Strict bool
+
+	// TagName is the struct tag key holding column names. If empty, the
+	// package-level tag name set with SetTagName is used, "sql" by default.
+	TagName string
+
+	// TagNames, if not empty, lists struct tag keys to look up in order,
+	// e.g. "sql", "db", "json", overriding TagName. The column name of a
+	// field is taken from the first of them the field has a non-empty tag
+	// for.
+	TagNames []string
+
+	// NameMapper derives the column name of fields without a tag from their
+	// Go field name, e.g. SnakeCase. If nil the field name is used as is.
+	// It must be set before the session is first used.
+	NameMapper func(string) string
+
+	// IncludeUntagged maps exported fields without a tag to columns named
+	// after the field (see NameMapper). If unset such fields are skipped,
+	// which allows mixing structs tagged for other domains, e.g. xml. It is
+	// set by NewSession.
+	IncludeUntagged bool
+
+	// Qualify makes Columns qualify each column with the name of the struct
+	// that holds it, e.g. "T"."f1" rather than "f1". It is set by NewSession.
+	Qualify bool
+
+	// CaseInsensitive matches result columns to fields regardless of case,
+	// for databases that fold column names to lower or upper case. Generated
+	// column lists keep the original case.
+	CaseInsensitive bool
+
+	// Dialect selects the identifier quoting and placeholder style of the
+	// generated SQL. The zero value quotes with double quotes.
+	Dialect Dialect
+
+	// MaxDepth limits how many levels of embedded structs have their fields
+	// flattened in to the embedding struct. Embedded structs nested deeper
+	// are mapped to a single column, avoiding accidental name collisions in
+	// large composed models. Zero means no limit. It must be set before the
+	// session is first used.
+	MaxDepth int
+
+	// ColumnOrder selects the order of generated column lists, and of the
+	// matching value lists. Scanning does not depend on it.
+	ColumnOrder ColumnOrder
+
+	// ColumnMatcher, if set, transforms each result column name before it is
+	// matched to a field, e.g. to strip the "table." qualifier some drivers
+	// add. It does not affect generated column lists, see NameMapper and
+	// ColumnNameTransform.
+	ColumnMatcher func(dbColumn string) string
+
+	// ColumnNameTransform, if set, transforms the column names of fields in
+	// the SQL generated by the session, e.g. with SnakeCase from the Go-style
+	// "UserID" of `sql:"UserID"` to the "user_id" of the database. It applies
+	// to column lists such as those of Columns and InsertColumns, and to the
+	// statements built by Where, UpdateSet and the like. Column names given
+	// to the session, such as those of ColumnsOnly, UpdateSet and UpsertStmt,
+	// are the transformed ones. It is the generate-side counterpart of
+	// ColumnMatcher and does not affect scanning, unless
+	// MatchTransformedNames is set.
+	ColumnNameTransform func(name string) string
+
+	// MatchTransformedNames makes scanning also match result columns to the
+	// names transformed by ColumnNameTransform, so that the columns generated
+	// by Columns or Join can be scanned back. The untransformed names take
+	// precedence. It must be set before the session is first used.
+	MatchTransformedNames bool
+
+	// ParseNumericStrings makes integer and floating point fields accept
+	// columns returned as strings or []byte, such as DECIMAL columns read
+	// over MySQL's text protocol, by parsing them with strconv.
+	ParseNumericStrings bool
+
+	// FlexibleBool makes bool fields accept the representations of booleans
+	// used by databases without a boolean type: the numbers 0 and 1 and,
+	// regardless of case, the strings "0", "1", "true", "false", "t", "f",
+	// "y" and "n".
+	FlexibleBool bool
+
+	// TrimStrings makes string fields drop the trailing spaces of their
+	// column values, as returned for CHAR(n) columns padded to their width.
+	// If TrimBoth is also set, leading spaces are dropped as well.
+	TrimStrings bool
+	TrimBoth    bool
+
+	// OnCollision, if set, is called with the column name shared by fields
+	// of embedded structs at the same depth, none of which is then mapped,
+	// as in Go, where such fields are ambiguous. It is called once per name
+	// when a struct type is first resolved, and never concurrently for the
+	// same type. Validate reports the same collisions.
+	OnCollision func(name string)
+
+	mu         sync.RWMutex
+	finfos     map[cacheKey]*typeInfo
+	tables     map[reflect.Type]string
+	converters map[reflect.Type]func(dst reflect.Value) sql.Scanner
+	arrays     map[reflect.Type]func(dst interface{}) sql.Scanner
+	named      map[string]func(dst reflect.Value) sql.Scanner
 }
 
+// ColumnOrder is the order of the columns generated for a struct.
+type ColumnOrder int
+
+const (
+	// Declaration orders columns as their fields are declared, with the
+	// fields of embedded structs in place of the embedded field.
+	Declaration ColumnOrder = iota
+
+	// Alphabetical orders columns by name, which keeps generated SQL stable
+	// when fields are moved around.
+	Alphabetical
+)
+
+// cacheKey identifies the field info of a struct type introspected with a
+// particular list of tag keys, joined by commas.
+type cacheKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var tagName = "sql"
+
+// SetTagName sets the struct tag key used by sessions that have no TagName of
+// their own, including the one behind the package-level functions.
+func SetTagName(name string) {
+	tagName = name
+}
+
+// std is the session used by the package-level functions.
+var std = NewSession()
+
 func NewSession() *Session {
 	return &Session{
-		finfos: make(map[reflect.Type][]field),
+		IncludeUntagged: true,
+		Qualify:         true,
+		finfos:          make(map[cacheKey]*typeInfo),
+		tables:          make(map[reflect.Type]string),
 	}
 }
 
-func (s *Session) Scan(dest interface{}, rows Rows) error {
-	destv := reflect.ValueOf(dest)
-	typ := destv.Type()
+// TableNamer may be implemented by structs to provide the table name their
+// columns are qualified with, instead of the name of the Go type.
+type TableNamer interface {
+	TableName() string
+}
 
-	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
-		panic(fmt.Errorf("dest must be pointer to struct; got %T", destv))
+// TableName sets the table name the columns of d's struct type are qualified
+// with, overriding both its TableNamer implementation and its type name.
+func (s *Session) TableName(d interface{}, name string) {
+	t := reflect.TypeOf(d)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
+	s.mu.Lock()
+	s.tables[t] = name
+	s.mu.Unlock()
+}
 
-	valtyp := typ.Elem()
-	fields, ok := s.finfos[valtyp]
-	if !ok {
-		fields = typeFields(valtyp)
-		s.finfos[valtyp] = fields
+// RegisterConverter makes fields of type t scan through the sql.Scanner
+// returned by conv, which is passed the field to store the column value in.
+// This lets types such as a [16]byte UUID be scanned from the string drivers
+// return without tagging every field. Fields are matched by their declared
+// type, so fields of type *T need a converter of their own. The json and time
+// tag options take precedence over a converter.
+func (s *Session) RegisterConverter(t reflect.Type, conv func(dst reflect.Value) sql.Scanner) {
+	s.mu.Lock()
+	if s.converters == nil {
+		s.converters = make(map[reflect.Type]func(dst reflect.Value) sql.Scanner)
 	}
+	s.converters[t] = conv
+	s.mu.Unlock()
+}
 
-	return scan(destv, fields, rows)
+// RegisterArrayScanner makes slice fields with elements of type elem scan
+// through the sql.Scanner returned by scanner, which is passed a pointer to
+// the field. It lets array columns be scanned in to plain slices, e.g.
+//
+//	s.RegisterArrayScanner(reflect.TypeOf(int64(0)), func(dst interface{}) sql.Scanner {
+//		return pq.Array(dst)
+//	})
+//
+// for []int64 fields. Converters registered for the slice type itself with
+// RegisterConverter take precedence.
+func (s *Session) RegisterArrayScanner(elem reflect.Type, scanner func(dst interface{}) sql.Scanner) {
+	s.mu.Lock()
+	if s.arrays == nil {
+		s.arrays = make(map[reflect.Type]func(dst interface{}) sql.Scanner)
+	}
+	s.arrays[elem] = scanner
+	s.mu.Unlock()
 }
 
+// RegisterNamedScanner registers factory under name for the fields tagged
+// with the "scanner=name" option, which scan through the sql.Scanner it
+// returns when passed the field:
+//
+//	Geom Point `sql:"geom,scanner=wkb"`
+//
+// Unlike RegisterConverter it applies to single fields rather than to all the
+// fields of a type, and takes precedence over converters and the json, time
+// and type options. Scanning a field naming an unregistered scanner fails.
+func (s *Session) RegisterNamedScanner(name string, factory func(dst reflect.Value) sql.Scanner) {
+	s.mu.Lock()
+	if s.named == nil {
+		s.named = make(map[string]func(dst reflect.Value) sql.Scanner)
+	}
+	s.named[name] = factory
+	s.mu.Unlock()
+}
+
+// tableName returns the name the columns of struct type t are qualified with.
+func (s *Session) tableName(t reflect.Type) string {
+	s.mu.RLock()
+	name, ok := s.tables[t]
+	s.mu.RUnlock()
+	if ok {
+		return name
+	}
+	if tn, ok := reflect.New(t).Interface().(TableNamer); ok {
+		return tn.TableName()
+	}
+	return t.Name()
+}
+
+// columnName returns the column name generated for a field named name,
+// transformed by the session's ColumnNameTransform.
+func (s *Session) columnName(name string) string {
+	if s.ColumnNameTransform != nil {
+		return s.ColumnNameTransform(name)
+	}
+	return name
+}
+
+// quoteColumn returns the quoted column name generated for a field named
+// name.
+func (s *Session) quoteColumn(name string) string {
+	return s.Dialect.Quote(s.columnName(name))
+}
+
+// logger returns the logger notices of s should be written to.
+func (s *Session) logger() Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return logger
+}
+
+// tagName returns the struct tag keys used by s, joined by commas.
+func (s *Session) tagName() string {
+	if len(s.TagNames) > 0 {
+		return strings.Join(s.TagNames, ",")
+	}
+	if s.TagName != "" {
+		return s.TagName
+	}
+	return tagName
+}
+
+// info returns the cached type info for t, computing it on a miss.
+func (s *Session) info(t reflect.Type) *typeInfo {
+	key := cacheKey{t, s.tagName()}
+	s.mu.RLock()
+	info, ok := s.finfos[key]
+	s.mu.RUnlock()
+	if ok {
+		return info
+	}
+
+	var collisions []string
+	s.mu.Lock()
+	if info, ok = s.finfos[key]; !ok {
+		opts := s.typeOptions(key.tag)
+		opts.collide = func(name string) { collisions = append(collisions, name) }
+		var transform func(string) string
+		if s.MatchTransformedNames {
+			transform = s.ColumnNameTransform
+		}
+		info = newTypeInfo(typeFields(t, opts), transform)
+		s.finfos[key] = info
+	}
+	s.mu.Unlock()
+
+	// Report collisions without holding the lock, so that OnCollision may
+	// use the session.
+	for _, name := range collisions {
+		s.logger().Printf("sqlstruct: column %s of %s is ambiguous and not mapped", name, t)
+		if s.OnCollision != nil {
+			s.OnCollision(name)
+		}
+	}
+	return info
+}
+
+// typeOptions returns the options s resolves fields with, given its tag
+// keys joined by commas.
+func (s *Session) typeOptions(tag string) typeOptions {
+	return typeOptions{
+		tagNames:        strings.Split(tag, ","),
+		nameMapper:      s.NameMapper,
+		includeUntagged: s.IncludeUntagged,
+		logger:          s.logger(),
+		maxDepth:        s.MaxDepth,
+	}
+}
+
+// Register populates the type info cache for the struct types of types, given
+// as structs or pointers to structs, so that the first scan of each type does
+// not pay for introspection. It is safe to call concurrently with scans and
+// panics if a value is not a struct or pointer to struct.
+func (s *Session) Register(types ...interface{}) {
+	for _, d := range types {
+		t := reflect.TypeOf(d)
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			panic(fmt.Errorf("%w: expected struct or pointer to struct; got %T", ErrInvalidDest, d))
+		}
+		s.info(t)
+	}
+}
+
+// CachedTypes returns the struct types in the type info cache, sorted by
+// their string representation.
+func (s *Session) CachedTypes() []reflect.Type {
+	s.mu.RLock()
+	seen := make(map[reflect.Type]bool, len(s.finfos))
+	types := make([]reflect.Type, 0, len(s.finfos))
+	for key := range s.finfos {
+		if !seen[key.typ] {
+			seen[key.typ] = true
+			types = append(types, key.typ)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+	return types
+}
+
+// fields returns the cached field info for t, in the session's ColumnOrder.
+func (s *Session) fields(t reflect.Type) []field {
+	if s.ColumnOrder == Alphabetical {
+		return s.info(t).sorted
+	}
+	return s.info(t).fields
+}
+
+// writeFields is like fields but also returns the fields tagged
+// "readignore", which may only be written.
+func (s *Session) writeFields(t reflect.Type) []field {
+	if s.ColumnOrder == Alphabetical {
+		return s.info(t).sortedWrites
+	}
+	return s.info(t).writes
+}
+
+// Scan scans the next row from rows in to the struct pointed to by dest. opts
+// override the settings of the session for this call only.
+//
+// Fields of type interface{} receive the value as returned by the driver,
+// i.e. an int64, float64, bool, []byte, string, time.Time or nil, unless they
+// are tagged with a "type=hint" option converting it to the type hint, one of
+// int64, float64, string, bool, bytes ([]byte) and time (time.Time):
+//
+//	Val interface{} `sql:"val,type=int64"`
+//
+// NULL is stored as nil.
+//
+// Struct fields mapped to a single column, including embedded structs
+// implementing driver.Valuer (see Columns), can only be scanned if they
+// implement sql.Scanner or encoding.TextUnmarshaler, or a converter applies
+// to them. Scanning a column in to any other struct field is an error, while
+// such fields without a column are left unchanged.
+func (s *Session) Scan(dest interface{}, rows Rows, opts ...ScanOption) error {
+	return s.scanStruct(dest, rows, "", s.config(opts))
+}
+
+// ScanCols is like Scan but maps the columns cols instead of calling
+// rows.Columns, which allocates, for loops scanning many rows of the same
+// result set. The caller is responsible for cols being the columns of rows, in
+// order.
+func (s *Session) ScanCols(dest interface{}, rows Rows, cols []string, opts ...ScanOption) error {
+	valtyp, err := structPtrType(dest)
+	if err != nil {
+		return err
+	}
+	cfg := s.config(opts)
+	p, err := s.plan(valtyp, s.info(valtyp), cols, "", cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.reset {
+		s.resetFields(reflect.ValueOf(dest).Elem())
+	}
+	return p.scan(reflect.ValueOf(dest), rows, make([]interface{}, len(cols)))
+}
+
+// ScanWithPrefix is like Scan but only considers the columns whose name starts
+// with colPrefix, mapping them with the prefix removed, and ignores all other
+// columns. Together with ColumnsWithPrefix it lets a single row populate
+// several structs, possibly of the same type, by scanning it once per struct:
+//
+//	q := "SELECT " + strings.Join(append(
+//		s.ColumnsWithPrefix(Person{}, "u", "u_"),
+//		s.ColumnsWithPrefix(Person{}, "m", "m_")...), ", ") +
+//		" FROM users u JOIN users m ON m.id = u.manager_id"
+//	...
+//	err = s.ScanWithPrefix(&user, rows, "u_")
+//	err = s.ScanWithPrefix(&manager, rows, "m_")
+//
+// This relies on rows allowing a row to be scanned repeatedly, as sql.Rows
+// does.
+func (s *Session) ScanWithPrefix(dest interface{}, rows Rows, colPrefix string, opts ...ScanOption) error {
+	return s.scanStruct(dest, rows, colPrefix, s.config(opts))
+}
+
+// ScanReset is like Scan but first sets every field of dest mapped to a
+// column to its zero value, whether or not rows has that column. Scan leaves
+// fields without a column unchanged, so a struct reused across rows would
+// otherwise keep values from earlier rows. It is short for Scan with the
+// ResetFields option.
+func (s *Session) ScanReset(dest interface{}, rows Rows) error {
+	return s.Scan(dest, rows, ResetFields())
+}
+
+// resetFields sets every field of the struct v mapped to a column to its zero
+// value.
+func (s *Session) resetFields(v reflect.Value) {
+	for _, f := range s.fields(v.Type()) {
+		if fv, ok := fieldValue(v, f.index); ok {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+}
+
+// ScanPositional is like Scan but binds the columns of rows to the fields of
+// dest in the order Columns lists them, which follows the session's
+// ColumnOrder, ignoring the column names. This saves looking them up for
+// queries whose column order is known, such as those built with Columns. It
+// returns an error if the number of columns is not the number of fields.
+func (s *Session) ScanPositional(dest interface{}, rows Rows) error {
+	valtyp, err := structPtrType(dest)
+	if err != nil {
+		return err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	info := s.info(valtyp)
+	fields := s.fields(valtyp)
+	if len(cols) != len(fields) {
+		return fmt.Errorf("sqlstruct: %d columns for the %d fields of %s", len(cols), len(fields), valtyp)
+	}
+	p := &ScanPlan{
+		typ:    valtyp,
+		cols:   cols,
+		fields: make([]*field, len(fields)),
+		flat:   info.flat,
+	}
+	for i := range fields {
+		p.fields[i] = &fields[i]
+	}
+	if err := s.bindConverters(p); err != nil {
+		return err
+	}
+	if err := p.checkStructs(); err != nil {
+		return err
+	}
+	return p.scan(reflect.ValueOf(dest), rows, make([]interface{}, len(cols)))
+}
+
+// ScanInto is like Scan but then copies the values of fields tagged with the
+// "nullsrc=Field" option, usually sql.Null* types, in to the plain field
+// Field of the same struct, which is typically excluded from the columns with
+// a "-" tag:
+//
+//	type User struct {
+//		Nick     string         `sql:"-"`
+//		NickNull sql.NullString `sql:"nick,nullsrc=Nick"`
+//	}
+//
+// The source field must implement driver.Valuer. Field is set to its zero
+// value if the source is NULL.
+func (s *Session) ScanInto(dest interface{}, rows Rows) error {
+	if err := s.Scan(dest, rows); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(dest).Elem()
+	return copyNullSources(v, s.fields(v.Type()))
+}
+
+func (s *Session) scanStruct(dest interface{}, rows Rows, prefix string, cfg scanConfig) error {
+	valtyp, err := structPtrType(dest)
+	if err != nil {
+		return err
+	}
+	_, err = s.scan(reflect.ValueOf(dest), s.info(valtyp), rows, prefix, cfg)
+	return err
+}
+
+// ScanStats is like Scan but also returns how many columns of rows were
+// mapped to fields of dest and how many were discarded. Discarded columns
+// usually mean a query selects more than it needs. The counts are zero if
+// the columns could not be mapped.
+func (s *Session) ScanStats(dest interface{}, rows Rows) (matched, discarded int, err error) {
+	valtyp, err := structPtrType(dest)
+	if err != nil {
+		return 0, 0, err
+	}
+	p, err := s.scan(reflect.ValueOf(dest), s.info(valtyp), rows, "", s.config(nil))
+	if p != nil {
+		for _, f := range p.fields {
+			if f != nil {
+				matched++
+			}
+		}
+		discarded = len(p.fields) - matched
+	}
+	return matched, discarded, err
+}
+
+// ScanTracked is like Scan but also returns the column names of the fields of
+// dest that were set from a column, in the order of the result columns, so
+// that patch or merge logic can tell them from the fields the database did
+// not provide. Fields only set from their default option are not included.
+func (s *Session) ScanTracked(dest interface{}, rows Rows) (setFields []string, err error) {
+	valtyp, err := structPtrType(dest)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.scan(reflect.ValueOf(dest), s.info(valtyp), rows, "", s.config(nil))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range p.fields {
+		if f != nil {
+			setFields = append(setFields, s.columnName(f.name))
+		}
+	}
+	return setFields, nil
+}
+
+// ScanAll scans all remaining rows in to the slice pointed to by dest, using the
+// session's type info cache. See the package-level ScanAll.
+func (s *Session) ScanAll(dest interface{}, rows Iterator, opts ...ScanOption) error {
+	return s.ScanAllContext(context.Background(), dest, rows, opts...)
+}
+
+// ScanAllContext is like ScanAll but returns ctx's error as soon as ctx is
+// done, checking it before each row is read. The rows scanned so far are kept
+// in dest. rows is not closed.
+func (s *Session) ScanAllContext(ctx context.Context, dest interface{}, rows Iterator, opts ...ScanOption) error {
+	valtyp, err := sliceStructType(dest)
+	if err != nil {
+		return err
+	}
+
+	if err := s.scanRows(ctx, reflect.ValueOf(dest), s.info(valtyp), rows, s.config(opts)); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// Iterate scans every remaining row of rows in to dest, a pointer to a
+// struct, and calls fn with dest after each row, so that large result sets
+// can be processed without holding them in memory. dest is reused: its mapped
+// fields are reset to their zero values before each row is scanned, and fn
+// must copy whatever it keeps. Iteration stops at the first error returned by
+// fn, which Iterate returns; otherwise the error returned by rows.Err is
+// returned once iteration is done. rows is not closed.
+func (s *Session) Iterate(dest interface{}, rows Iterator, fn func(dest interface{}) error) error {
+	valtyp, err := structPtrType(dest)
+	if err != nil {
+		return err
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	p, err := s.plan(valtyp, s.info(valtyp), cols, "", s.config(nil))
+	if err != nil {
+		return err
+	}
+	destv := reflect.ValueOf(dest)
+	values := make([]interface{}, len(cols))
+	for rows.Next() {
+		s.resetFields(destv.Elem())
+		if err := p.scan(destv, rows, values); err != nil {
+			return err
+		}
+		if err := fn(dest); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RowsCloser defines the interface of types that can be scanned and closed
+// with the ScanAllClose function. It is implemented by the sql.Rows type from
+// the standard library
+type RowsCloser interface {
+	Iterator
+	Close() error
+}
+
+// ScanAllClose is like ScanAll but always closes rows. It returns the first
+// error encountered, in this order of precedence: an error scanning a row (or
+// an invalid dest), then the error returned by rows.Close, then the error
+// returned by rows.Err.
+func (s *Session) ScanAllClose(dest interface{}, rows RowsCloser, opts ...ScanOption) error {
+	valtyp, err := sliceStructType(dest)
+	if err == nil {
+		err = s.scanRows(context.Background(), reflect.ValueOf(dest), s.info(valtyp), rows, s.config(opts))
+	}
+	if cerr := rows.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	return err
+}
+
+// Columns returns the column list of d, a struct or pointer to struct, or nil
+// if d is not one. The columns of an embedded struct tagged with the "star"
+// option, such as
+//
+//	type UserOrder struct {
+//		User  `sql:",star"`
+//		Total int `sql:"total"`
+//	}
+//
+// are selected with a single "User".* (or * if the session does not Qualify
+// columns) and scanned by name.
+//
+// Embedded structs implementing driver.Valuer, such as a money type written
+// as a single value, are a single column named after the type rather than
+// having their fields flattened in to d.
 func (s *Session) Columns(d interface{}) (names []string) {
-	v := reflect.ValueOf(d)
-	valtyp := v.Type()
-	fields, ok := s.finfos[valtyp]
-	if !ok {
-		fields = typeFields(valtyp)
-		s.finfos[valtyp] = fields
+	names, _ = s.ColumnsErr(d)
+	return
+}
+
+// ColumnsErr is like Columns but returns an error wrapping ErrInvalidDest if d
+// is not a struct.
+func (s *Session) ColumnsErr(d interface{}) ([]string, error) {
+	v, err := structValue(d)
+	if err != nil {
+		return nil, err
+	}
+	return s.columns(s.fields(v.Type())), nil
+}
+
+// MustColumns is like ColumnsErr but panics if d is not a struct.
+func (s *Session) MustColumns(d interface{}) []string {
+	names, err := s.ColumnsErr(d)
+	if err != nil {
+		panic(err)
+	}
+	return names
+}
+
+// ColumnsString returns the columns of d joined with ", ", ready to be used
+// as the select list of a query, or "" if d is not a struct.
+func (s *Session) ColumnsString(d interface{}) string {
+	return strings.Join(s.Columns(d), ", ")
+}
+
+// ColumnsExcept is like Columns but leaves out the columns named in except.
+// Names are matched against the column names, as set by tags or the
+// NameMapper and transformed by the ColumnNameTransform, not against the Go
+// field names.
+func (s *Session) ColumnsExcept(d interface{}, except ...string) []string {
+	v, err := structValue(d)
+	if err != nil {
+		return nil
+	}
+	fields := s.fields(v.Type())
+	kept := make([]field, 0, len(fields))
+	for _, f := range fields {
+		if !containsString(except, s.columnName(f.name)) {
+			kept = append(kept, f)
+		}
+	}
+	return s.columns(kept)
+}
+
+// ColumnsOnly is like Columns but returns only the columns named in only, in
+// the order given. Names are matched against the column names. Names that are
+// not columns of d are skipped and reported to the Logger, so a stale name
+// narrows the list rather than failing the query.
+func (s *Session) ColumnsOnly(d interface{}, only ...string) []string {
+	v, err := structValue(d)
+	if err != nil {
+		return nil
+	}
+	fields := s.fields(v.Type())
+	kept := make([]field, 0, len(only))
+	for _, name := range only {
+		f, ok := s.fieldByColumn(fields, name)
+		if !ok {
+			s.logger().Printf("sqlstruct: no column %s in %s", name, v.Type())
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return s.columns(kept)
+}
+
+// fieldByColumn returns the field of fields whose generated column name is
+// name.
+func (s *Session) fieldByColumn(fields []field, name string) (field, bool) {
+	for _, f := range fields {
+		if s.columnName(f.name) == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// ColumnsWithPrefix is like Columns but qualifies every column with
+// tableAlias and aliases it as colPrefix followed by its column name, e.g.
+// "u"."Name" as "u_name". The columns can be scanned back with ScanWithPrefix
+// and the same colPrefix. tableAlias replaces the table names that Columns
+// derives from the struct declaring each field (its ctx), including those of
+// embedded structs tagged with the prefix option, so all columns of d must
+// come from the same aliased table. An empty tableAlias leaves the columns
+// unqualified. It returns nil if d is not a struct.
+func (s *Session) ColumnsWithPrefix(d interface{}, tableAlias, colPrefix string) []string {
+	v, err := structValue(d)
+	if err != nil {
+		return nil
+	}
+	fields := s.fields(v.Type())
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f.ctx = tableAlias
+		f.name = colPrefix + s.columnName(f.name)
+		names = append(names, f.colName(tableAlias != "", s.Dialect))
+	}
+	return names
+}
+
+// Join returns the select list of a query joining the tables of items,
+// structs or pointers to structs, as in
+//
+//	q := "SELECT " + s.Join(User{}, Order{}) +
+//		" FROM users JOIN orders ON orders.user_id = users.id"
+//
+// The columns of each item are qualified with its table name (see
+// TableNamer), unless the session does not Qualify columns, and aliased as
+// the table name, a dot and the column name, e.g. "users"."ID" as
+// "users.id", so that ScanJoin can scan each row back in to structs of the
+// same types. A struct type may therefore only be given once. It returns ""
+// if an item is not a struct.
+func (s *Session) Join(items ...interface{}) string {
+	var names []string
+	for _, d := range items {
+		v, err := structValue(d)
+		if err != nil {
+			return ""
+		}
+		table := s.tableName(v.Type())
+		alias := ""
+		if s.Qualify {
+			alias = table
+		}
+		names = append(names, s.ColumnsWithPrefix(d, alias, table+".")...)
+	}
+	return strings.Join(names, ", ")
+}
+
+// ScanJoin scans the current row of rows, selected with the columns returned
+// by Join, in to dests, pointers to structs of the types given to Join, in
+// any order. Each dest is populated from the columns aliased with its table
+// name as done by ScanWithPrefix, so rows must allow a row to be scanned
+// repeatedly, as sql.Rows does.
+func (s *Session) ScanJoin(rows Rows, dests ...interface{}) error {
+	for _, dest := range dests {
+		t, err := structPtrType(dest)
+		if err != nil {
+			return err
+		}
+		if err := s.ScanWithPrefix(dest, rows, s.tableName(t)+"."); err != nil {
+			return err
+		}
 	}
-	return columns(v, fields)
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the values of the fields of the struct d in the same order
+// as Columns returns their names, or nil if d is not a struct.
+func (s *Session) Values(d interface{}) []interface{} {
+	vals, _ := s.ValuesErr(d)
+	return vals
+}
+
+// ValuesErr is like Values but returns an error wrapping ErrInvalidDest if d
+// is not a struct.
+func (s *Session) ValuesErr(d interface{}) ([]interface{}, error) {
+	v, err := structValue(d)
+	if err != nil {
+		return nil, err
+	}
+	return values(v, s.fields(v.Type())), nil
+}
+
+// MustValues is like ValuesErr but panics if d is not a struct.
+func (s *Session) MustValues(d interface{}) []interface{} {
+	vals, err := s.ValuesErr(d)
+	if err != nil {
+		panic(err)
+	}
+	return vals
+}
+
+// Placeholders returns one placeholder in the style of the session's Dialect
+// per column of d, separated by commas, or "" if d is not a struct.
+func (s *Session) Placeholders(d interface{}) string {
+	v, err := structValue(d)
+	if err != nil {
+		return ""
+	}
+	return PlaceholdersN(len(s.fields(v.Type())), s.Dialect)
+}
+
+// InsertColumns returns the quoted names and the values of the columns of d
+// to be written by an INSERT statement, in the same order as Columns. Fields
+// tagged with the "omitempty" option are left out if they hold their zero
+// value, letting the database apply its column default. Since the decision
+// depends on a concrete value, omitempty is only honoured by value-aware
+// methods such as this one and never by Columns. Fields tagged "readonly" or
+// "writeignore" are always left out, while those tagged "readignore" are
+// included even though Columns leaves them out.
+func (s *Session) InsertColumns(d interface{}) (names []string, vals []interface{}) {
+	v, err := structValue(d)
+	if err != nil {
+		return nil, nil
+	}
+	for _, f := range s.writeFields(v.Type()) {
+		if !f.writable() {
+			continue
+		}
+		fv, ok := fieldValue(v, f.index)
+		if f.opts.contains("omitempty") && (!ok || fv.IsZero()) {
+			continue
+		}
+		var val interface{}
+		if ok {
+			val = fv.Interface()
+		}
+		names = append(names, s.quoteColumn(f.name))
+		vals = append(vals, val)
+	}
+	return
+}
+
+// WritableColumns returns the quoted names of the columns of d that may be
+// written by INSERT or UPDATE statements, i.e. all columns except those of
+// fields tagged with the "readonly" option, such as generated ids, or its
+// synonym "writeignore". Columns, used for SELECT lists, still includes them.
+// Conversely, the columns of fields tagged "readignore", such as password
+// hashes, are only included here and not by Columns, nor are they scanned.
+// It returns nil if d is not a struct.
+func (s *Session) WritableColumns(d interface{}) []string {
+	v, err := structValue(d)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range s.writeFields(v.Type()) {
+		if f.writable() {
+			names = append(names, s.quoteColumn(f.name))
+		}
+	}
+	return names
+}
+
+// ReturningColumns returns the columns of d as bare quoted names joined with
+// ", ", for RETURNING clauses such as "INSERT ... RETURNING "id", "name"".
+// Unlike Columns, the names are never qualified with a table, whatever the
+// session's Qualify. It returns "" if d is not a struct.
+func (s *Session) ReturningColumns(d interface{}) string {
+	v, err := structValue(d)
+	if err != nil {
+		return ""
+	}
+	fields := s.fields(v.Type())
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = s.quoteColumn(f.name)
+	}
+	return strings.Join(names, ", ")
 }
 
+// PrimaryKey returns the quoted column name and the value of the field of d
+// tagged with the "pk" option, for building statements such as
+// "UPDATE t SET ... WHERE <name> = ?". It returns ErrNoPrimaryKey if there is
+// no such field and an error if more than one field is tagged "pk".
+func (s *Session) PrimaryKey(d interface{}) (name string, value interface{}, err error) {
+	v, err := structValue(d)
+	if err != nil {
+		return "", nil, err
+	}
+	var pk *field
+	for _, f := range s.fields(v.Type()) {
+		if !f.opts.contains("pk") {
+			continue
+		}
+		if pk != nil {
+			return "", nil, fmt.Errorf("sqlstruct: %s has more than one pk field: %s and %s",
+				v.Type(), pk.fname, f.fname)
+		}
+		f := f
+		pk = &f
+	}
+	if pk == nil {
+		return "", nil, ErrNoPrimaryKey
+	}
+	if fv, ok := fieldValue(v, pk.index); ok {
+		value = fv.Interface()
+	}
+	return s.quoteColumn(pk.name), value, nil
+}
+
+// MustScan is like Scan but panics with the error instead of returning it.
+// Use errors.Is on the recovered error to tell an invalid dest
+// (ErrInvalidDest) from a field that could not be bound (ErrFieldAccess).
 func (s *Session) MustScan(dest interface{}, rows Rows) {
 	if err := s.Scan(dest, rows); err != nil {
 		panic(err)
 	}
 }
 
+// Iterator defines the interface of types that can be iterated over with the
+// ScanAll function. It is implemented by the sql.Rows type from the standard library
+type Iterator interface {
+	Rows
+	Next() bool
+	Err() error
+}
+
 // Scan scans the next row from rows in to a struct pointed to by dest. The struct type
 // should have exported fields tagged with the "sql" tag. Columns from row which are not
 // mapped to any struct fields are ignored. Struct fields which have no matching column
-// in the result set are left unchanged.
-func scan(destv reflect.Value, fields []field, rows Rows) error {
-	finfos := make(map[string]field)
-	for _, f := range fields {
-		finfos[f.name] = f
+// in the result set are left unchanged. If cfg is strict, unmapped columns are an
+// error instead. If cfg resets fields, the mapped fields are zeroed once the
+// plan is resolved, so dest is left untouched if it cannot be. It returns
+// the plan used, or nil if none could be resolved.
+func (s *Session) scan(destv reflect.Value, info *typeInfo, rows Rows, prefix string, cfg scanConfig) (*ScanPlan, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
 	}
 
-	elem := destv.Elem()
-	var values []interface{}
+	p, err := s.plan(destv.Type().Elem(), info, cols, prefix, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.reset {
+		s.resetFields(destv.Elem())
+	}
+	return p, p.scan(destv, rows, make([]interface{}, len(cols)))
+}
 
-	cols, err := rows.Columns()
+// ScanPlan binds the columns of a result set to the fields of a struct type.
+// It is resolved once, so scanning a row with it needs no column name
+// lookups. A ScanPlan is safe for concurrent use by multiple goroutines.
+type ScanPlan struct {
+	typ      reflect.Type
+	cols     []string // names of the result columns
+	fields   []*field // field mapped to each column, nil if unmapped
+	defaults []*field // fields with a default but no column
+
+	// Registered converter of the field mapped to each column, nil if no
+	// converter applies to any column.
+	convs []func(dst reflect.Value) sql.Scanner
+
+	rest      *field   // field collecting unmapped columns, if any
+	restCols  []int    // unmapped columns collected in rest
+	restNames []string // names of restCols
+
+	flat bool // no field is promoted from an embedded struct
+}
+
+// Plan resolves which field of dest's struct type each of the result columns
+// cols maps to. dest is a struct or pointer to struct and only its type is
+// used, so (*T)(nil) may be passed to precompute plans at startup. Plan fails
+// on unmapped columns if the session is Strict.
+func (s *Session) Plan(dest interface{}, cols []string) (*ScanPlan, error) {
+	t := reflect.TypeOf(dest)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: expected struct or pointer to struct; got %T", ErrInvalidDest, dest)
+	}
+	return s.plan(t, s.info(t), cols, "", s.config(nil))
+}
+
+// Explain returns a table describing how the result columns cols would be
+// mapped to the fields of dest, a struct or pointer to struct, for debugging
+// mappings. Each column is listed with the field it is scanned in to, that
+// field's type and index path, or as discarded. Fields set from their
+// default option because they have no column are listed last.
+func (s *Session) Explain(dest interface{}, cols []string) string {
+	t := reflect.TypeOf(dest)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Sprintf("%v: expected struct or pointer to struct; got %T\n", ErrInvalidDest, dest)
+	}
+	p, err := s.plan(t, s.info(t), cols, "", scanConfig{logger: s.logger()})
 	if err != nil {
-		return err
+		return err.Error() + "\n"
+	}
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COLUMN\tFIELD\tTYPE\tINDEX\tNOTE")
+	rest := make(map[int]bool, len(p.restCols))
+	for _, i := range p.restCols {
+		rest[i] = true
+	}
+	for i, col := range cols {
+		f := p.fields[i]
+		switch {
+		case f != nil:
+			note := ""
+			if p.convs != nil && p.convs[i] != nil {
+				note = "converter"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n", col, f.fname, f.typ, f.index, note)
+		case rest[i]:
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\trest\n", col, p.rest.fname, p.rest.typ, p.rest.index)
+		default:
+			fmt.Fprintf(w, "%s\t-\t-\t-\tdiscarded\n", col)
+		}
+	}
+	for _, f := range p.defaults {
+		def, _ := f.opts.get("default")
+		fmt.Fprintf(w, "-\t%s\t%s\t%v\tdefault %q\n", f.fname, f.typ, f.index, def)
 	}
+	w.Flush()
+	return b.String()
+}
 
-	for _, name := range cols {
-		fi, ok := finfos[name]
-		var v interface{}
+// plan resolves the ScanPlan of cols for t. If prefix is not empty only the
+// columns starting with it are considered, with the prefix removed, and the
+// other columns are silently discarded. If a column name occurs more than
+// once, e.g. "id" in a JOIN, only its last occurrence is bound to the field
+// and the others are discarded. If cfg is strict, unmapped and duplicate
+// columns are an error.
+func (s *Session) plan(t reflect.Type, info *typeInfo, cols []string, prefix string, cfg scanConfig) (*ScanPlan, error) {
+	p := &ScanPlan{
+		typ:    t,
+		cols:   cols,
+		fields: make([]*field, len(cols)),
+		flat:   info.flat,
+	}
+	var unmapped []string
+	var pending []int // columns that may match an alias
+	var pendingKeys []string
+	switch len(info.rest) {
+	case 0:
+	case 1:
+		if info.rest[0].typ != restType {
+			return nil, fmt.Errorf("sqlstruct: rest field %s must be of type %s; got %s", info.rest[0].fname, restType, info.rest[0].typ)
+		}
+		p.rest = &info.rest[0]
+	default:
+		return nil, fmt.Errorf("sqlstruct: multiple rest fields in %s", t)
+	}
+	bound := make(map[string]int, len(cols))
+	names, aliases := info.names, info.aliases
+	if s.CaseInsensitive {
+		names, aliases = info.folded, info.foldedAliases
+	}
+
+	for i, name := range cols {
+		if s.ColumnMatcher != nil {
+			name = s.ColumnMatcher(name)
+		}
+		if prefix != "" {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			name = name[len(prefix):]
+		}
+		key := name
+		if s.CaseInsensitive {
+			key = strings.ToLower(name)
+		}
+		fi, ok := names[key]
 		if !ok {
-			//fmt.Println("sqlstruct: no field for", name)
+			if _, ok := aliases[key]; ok {
+				pending = append(pending, i)
+				pendingKeys = append(pendingKeys, key)
+				continue
+			}
+			if p.rest != nil {
+				p.restCols = append(p.restCols, i)
+				p.restNames = append(p.restNames, name)
+				continue
+			}
 			// There is no field mapped to this column so we discard it
-			v = &sql.RawBytes{}
-		} else {
-			v = elem.FieldByIndex(fi.index).Addr().Interface()
+			cfg.logger.Printf("sqlstruct: no field for %s", name)
+			unmapped = append(unmapped, name)
+			continue
 		}
-		values = append(values, v)
+		if j, ok := bound[key]; ok {
+			if cfg.strict {
+				return nil, &columnError{ErrDuplicateColumn, fmt.Sprintf("sqlstruct: duplicate column %q", name)}
+			}
+			cfg.logger.Printf("sqlstruct: duplicate column %s, discarding earlier occurrence", name)
+			p.fields[j] = nil
+		}
+		bound[key] = i
+		p.fields[i] = &fi
 	}
 
+	// Aliases only apply to fields whose column is missing.
+	for j, i := range pending {
+		fi := aliases[pendingKeys[j]]
+		fkey := fi.name
+		if s.CaseInsensitive {
+			fkey = strings.ToLower(fkey)
+		}
+		if _, ok := bound[fkey]; ok {
+			// Shadowed by the field's column or an earlier alias.
+			continue
+		}
+		bound[fkey] = i
+		p.fields[i] = &fi
+	}
+
+	for i, f := range info.fields {
+		if _, ok := f.opts.get("default"); !ok {
+			continue
+		}
+		key := f.name
+		if s.CaseInsensitive {
+			key = strings.ToLower(key)
+		}
+		if _, ok := bound[key]; !ok {
+			p.defaults = append(p.defaults, &info.fields[i])
+		}
+	}
+
+	if cfg.strict && len(unmapped) > 0 {
+		return nil, unmappedError(unmapped)
+	}
+
+	if err := s.bindConverters(p); err != nil {
+		return nil, err
+	}
+	if err := p.checkStructs(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkStructs returns an error if a column of p maps to a struct field that
+// cannot be scanned in to, as reported by unscannableStruct, unless a
+// converter applies to it.
+func (p *ScanPlan) checkStructs() error {
+	for i, f := range p.fields {
+		if f != nil && (p.convs == nil || p.convs[i] == nil) && unscannableStruct(f) {
+			return fmt.Errorf("sqlstruct: column %s maps to field %s of struct type %s, which is not a sql.Scanner; tag it with the prefix option to map its fields", p.cols[i], f.fname, f.typ)
+		}
+	}
+	return nil
+}
+
+// bindConverters sets the converters of the fields of p, if any applies. It
+// returns an error if a field names a scanner that is not registered.
+func (s *Session) bindConverters(p *ScanPlan) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	implicit := len(s.converters) > 0 || len(s.arrays) > 0 || s.ParseNumericStrings || s.FlexibleBool || s.TrimStrings
+	for i, f := range p.fields {
+		if f == nil {
+			continue
+		}
+		var conv func(dst reflect.Value) sql.Scanner
+		var ok bool
+		if name, named := f.opts.get("scanner"); named {
+			if conv, ok = s.named[name]; !ok {
+				return fmt.Errorf("sqlstruct: field %s uses unregistered scanner %q", f.fname, name)
+			}
+		} else if implicit {
+			conv, ok = s.implicitConverter(f)
+		}
+		if ok {
+			if p.convs == nil {
+				p.convs = make([]func(dst reflect.Value) sql.Scanner, len(p.cols))
+			}
+			p.convs[i] = conv
+		}
+	}
+	return nil
+}
+
+// implicitConverter returns the converter applying to f according to its
+// type and the settings of s, if any. The json, time and type tag options
+// take precedence over converters. s.mu must be held.
+func (s *Session) implicitConverter(f *field) (func(dst reflect.Value) sql.Scanner, bool) {
+	if f.opts.contains("json") {
+		return nil, false
+	}
+	if _, ok := f.opts.get("time"); ok {
+		return nil, false
+	}
+	if _, ok := f.opts.get("type"); ok {
+		return nil, false
+	}
+	if conv, ok := s.converters[f.decl]; ok {
+		return conv, true
+	}
+	if f.typ.Kind() == reflect.Slice {
+		if scanner, found := s.arrays[f.typ.Elem()]; found {
+			return func(dst reflect.Value) sql.Scanner {
+				return scanner(dst.Addr().Interface())
+			}, true
+		}
+	}
+	if s.ParseNumericStrings && isNumeric(f.typ) {
+		return newNumericScanner, true
+	}
+	if s.FlexibleBool && isBool(f.typ) {
+		return newBoolScanner, true
+	}
+	if s.TrimStrings && isString(f.typ) {
+		both := s.TrimBoth
+		return func(dst reflect.Value) sql.Scanner {
+			return &trimScanner{dst, both}
+		}, true
+	}
+	return nil, false
+}
+
+// Scan scans the current row of rows in to the struct pointed to by dest,
+// which must be of the type the plan was made for. The columns of rows must
+// be the ones passed to Plan.
+func (p *ScanPlan) Scan(dest interface{}, rows Rows) error {
+	if t, err := structPtrType(dest); err != nil {
+		return err
+	} else if t != p.typ {
+		return fmt.Errorf("%w: plan is for *%s; got %T", ErrInvalidDest, p.typ, dest)
+	}
+	return p.scan(reflect.ValueOf(dest), rows, make([]interface{}, len(p.fields)))
+}
+
+// scan scans the current row of rows in to the struct pointed to by destv,
+// using values, which has one element per column, as rows.Scan destinations.
+// Fields tagged with a "default=value" option that have no column in the
+// result set are set to that value.
+func (p *ScanPlan) scan(destv reflect.Value, rows Rows, values []interface{}) error {
+	if err := p.bind(destv.Elem(), values); err != nil {
+		return err
+	}
 	if err := rows.Scan(values...); err != nil {
+		return p.scanError(rows, values, err)
+	}
+	if p.rest != nil {
+		rest := make(map[string]interface{}, len(p.restCols))
+		for j, i := range p.restCols {
+			v := *values[i].(*interface{})
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			rest[p.restNames[j]] = v
+		}
+		p.field(destv.Elem(), p.rest).Set(reflect.ValueOf(rest))
+	}
+	return nil
+}
+
+// bind applies the defaults of p to elem and sets values to the destinations
+// of the columns. A panic while accessing a field is returned as an error
+// wrapping ErrFieldAccess.
+func (p *ScanPlan) bind(elem reflect.Value, values []interface{}) (err error) {
+	var cur *field
+	defer func() {
+		if r := recover(); r != nil && cur != nil {
+			err = fmt.Errorf("%w %s (index %v) of %s: %v", ErrFieldAccess, cur.fname, cur.index, p.typ, r)
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+
+	for _, f := range p.defaults {
+		cur = f
+		def, _ := f.opts.get("default")
+		if err := setString(p.field(elem, f), def); err != nil {
+			return fmt.Errorf("sqlstruct: default of field %s: %w", f.fname, err)
+		}
+	}
+	for i, f := range p.fields {
+		cur = f
+		if f != nil && p.convs != nil && p.convs[i] != nil {
+			values[i] = p.convs[i](p.field(elem, f))
+		} else if f != nil {
+			values[i] = fieldDest(f, p.field(elem, f))
+		} else if values[i] == nil {
+			// Each unmapped column gets a destination of its own. Scanning
+			// in to an interface{} copies the value, so no driver memory
+			// is retained or aliased.
+			values[i] = new(interface{})
+		}
+	}
+	return nil
+}
+
+// scanError identifies the column that made rows.Scan fail with err, by
+// scanning again one column at a time, and returns a *ScanError naming the
+// column and the field it maps to. This relies on rows allowing a row to be
+// scanned repeatedly, as sql.Rows does.
+func (p *ScanPlan) scanError(rows Rows, values []interface{}, err error) error {
+	probe := make([]interface{}, len(values))
+	for j := range probe {
+		probe[j] = new(interface{})
+	}
+	if rows.Scan(probe...) != nil {
+		// The failure does not depend on any destination, e.g. sql.ErrNoRows.
 		return err
 	}
+	for i, f := range p.fields {
+		if f == nil {
+			continue
+		}
+		for j := range probe {
+			probe[j] = new(interface{})
+		}
+		probe[i] = values[i]
+		if perr := rows.Scan(probe...); perr != nil {
+			return &ScanError{p.cols[i], f.fname, f.decl, perr}
+		}
+	}
+	return err
+}
+
+// field returns the field f of elem. Fields of flat structs are accessed
+// directly, without walking their index.
+func (p *ScanPlan) field(elem reflect.Value, f *field) reflect.Value {
+	if p.flat {
+		return elem.Field(f.index[0])
+	}
+	return fieldByIndex(elem, f.index)
+}
+
+// fieldByIndex is like v.FieldByIndex but allocates any nil embedded struct
+// pointers along the index path instead of panicking.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+var (
+	restType            = reflect.TypeOf(map[string]interface{}(nil))
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	valuerType          = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// fieldDest returns the destination rows.Scan should store a column mapped to
+// the field f, whose value is fv, in to. Fields tagged "json" are decoded by
+// a jsonScanner, fields tagged "time=layout" parsed by a timeScanner and
+// those tagged "type=hint" converted by a hintScanner. Fields whose type
+// implements sql.Scanner are passed as the Scanner itself, those implementing
+// encoding.TextUnmarshaler are fed the column's text by a textScanner and
+// everything else is passed as a pointer to the field.
+func fieldDest(f *field, fv reflect.Value) interface{} {
+	addr := fv.Addr()
+	if f.opts.contains("json") {
+		return &jsonScanner{addr.Interface()}
+	}
+	if layout, ok := f.opts.get("time"); ok {
+		return &timeScanner{fv, layout}
+	}
+	if hint, ok := f.opts.get("type"); ok {
+		return &hintScanner{fv, hint}
+	}
+	if fv.Kind() != reflect.Ptr && addr.Type().Implements(scannerType) {
+		return addr.Interface().(sql.Scanner)
+	}
+	if fv.Type() != timeType && addr.Type().Implements(textUnmarshalerType) {
+		// time.Time values are scanned as such rather than parsed.
+		return &textScanner{addr.Interface().(encoding.TextUnmarshaler)}
+	}
+	if fv.Kind() == reflect.Ptr {
+		if _, ok := nullTypes[fv.Type().Elem()]; ok {
+			return &nullScanner{fv}
+		}
+		if fv.Type().Implements(scannerType) {
+			return &ptrScanner{fv}
+		}
+	}
+	return addr.Interface()
+}
+
+// scanRows scans every remaining row of rows in to a freshly allocated element
+// appended to the slice pointed to by destv. The columns and the field map are
+// resolved once for the whole result set. Scanning stops with ctx's error if
+// ctx is done before a row is read. It is up to the caller to check rows.Err.
+func (s *Session) scanRows(ctx context.Context, destv reflect.Value, info *typeInfo, rows Iterator, cfg scanConfig) error {
+	slicev := destv.Elem()
+	elemtyp := slicev.Type().Elem()
+	isPtr := elemtyp.Kind() == reflect.Ptr
+	if isPtr {
+		elemtyp = elemtyp.Elem()
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	p, err := s.plan(elemtyp, info, cols, "", cfg)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(cols))
+
+	slicev.Set(reflect.MakeSlice(slicev.Type(), 0, 0))
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !rows.Next() {
+			break
+		}
+		v := reflect.New(elemtyp)
+		if err := p.scan(v, rows, values); err != nil {
+			return err
+		}
+		if isPtr {
+			slicev.Set(reflect.Append(slicev, v))
+		} else {
+			slicev.Set(reflect.Append(slicev, v.Elem()))
+		}
+	}
 
 	return nil
 }
 
-func columns(v reflect.Value, fields []field) (names []string) {
+// unmappedError returns the error reported by strict scans for the result
+// columns cols that have no matching struct field.
+func unmappedError(cols []string) error {
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = fmt.Sprintf("%q", c)
+	}
+	noun := "column"
+	if len(cols) > 1 {
+		noun = "columns"
+	}
+	return &columnError{ErrUnmappedColumn, fmt.Sprintf("sqlstruct: unmapped %s %s", noun, strings.Join(quoted, ", "))}
+}
+
+// structPtrType returns the struct type pointed to by dest, which must be a
+// non-nil pointer to a struct.
+func structPtrType(dest interface{}) (reflect.Type, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Type().Elem().Kind() != reflect.Struct || v.IsNil() {
+		return nil, fmt.Errorf("%w: dest must be non-nil pointer to struct; got %T", ErrInvalidDest, dest)
+	}
+	return v.Type().Elem(), nil
+}
+
+// sliceStructType returns the struct type of the elements of the slice pointed
+// to by dest. The elements may be structs or pointers to structs.
+func sliceStructType(dest interface{}) (reflect.Type, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Slice && !v.IsNil() {
+		elemtyp := v.Type().Elem().Elem()
+		if elemtyp.Kind() == reflect.Ptr {
+			elemtyp = elemtyp.Elem()
+		}
+		if elemtyp.Kind() == reflect.Struct {
+			return elemtyp, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: dest must be non-nil pointer to slice of structs; got %T", ErrInvalidDest, dest)
+}
+
+// structValue returns the struct value of d, which must be a struct or a
+// non-nil pointer to one.
+func structValue(d interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(d)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%w: expected struct or pointer to struct; got %T", ErrInvalidDest, d)
+	}
+	return v, nil
+}
+
+// fieldValue is like v.FieldByIndex but reports false instead of panicking if
+// the index path runs through a nil embedded struct pointer.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// values returns the values of fields in v. Fields behind a nil embedded
+// pointer are reported as nil.
+func values(v reflect.Value, fields []field) []interface{} {
+	vals := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		var val interface{}
+		if fv, ok := fieldValue(v, f.index); ok {
+			val = fv.Interface()
+		}
+		vals = append(vals, val)
+	}
+	return vals
+}
+
+// columns returns the column list of fields. The fields of an embedded
+// struct tagged with the "star" option are selected as a whole, with a single
+// "Table".* in place of its first field, or * if the session does not
+// Qualify columns.
+func (s *Session) columns(fields []field) (names []string) {
 	names = make([]string, 0, len(fields))
+	var stars map[string]bool
 	for _, f := range fields {
-		names = append(names, f.ColName())
+		if f.star != "" {
+			if stars[f.star] {
+				continue
+			}
+			if stars == nil {
+				stars = make(map[string]bool)
+			}
+			stars[f.star] = true
+			if s.Qualify {
+				names = append(names, s.Dialect.Quote(s.tableName(f.ctxt))+".*")
+			} else {
+				names = append(names, "*")
+			}
+			continue
+		}
+		if s.Qualify {
+			f.ctx = s.tableName(f.ctxt)
+		}
+		f.name = s.columnName(f.name)
+		names = append(names, f.colName(s.Qualify, s.Dialect))
 	}
 
 	return
 }
 
-func Scan(dest interface{}, rows Rows) error {
-	destv := reflect.ValueOf(dest)
-	typ := destv.Type()
+// Join returns the select list of a query joining the tables of items. See
+// Session.Join.
+func Join(items ...interface{}) string {
+	return std.Join(items...)
+}
 
-	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
-		panic(fmt.Errorf("dest must be pointer to struct; got %T", destv))
-	}
+// ScanJoin scans the current row of rows, selected with the columns returned
+// by Join, in to dests. See Session.ScanJoin.
+func ScanJoin(rows Rows, dests ...interface{}) error {
+	return std.ScanJoin(rows, dests...)
+}
 
-	return scan(destv, typeFields(typ.Elem()), rows)
+func Scan(dest interface{}, rows Rows, opts ...ScanOption) error {
+	return std.Scan(dest, rows, opts...)
 }
 
+// Columns returns the column list of s, a struct or pointer to struct, or nil
+// if s is not one.
 func Columns(s interface{}) (names []string) {
-	v := reflect.ValueOf(s)
-	fields := typeFields(v.Type())
-	return columns(v, fields)
+	names, _ = ColumnsErr(s)
+	return
+}
+
+// ColumnsString returns the columns of s joined with ", ", or "" if s is not
+// a struct:
+//
+//	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM t", sqlstruct.ColumnsString(T{})))
+func ColumnsString(s interface{}) string {
+	return std.ColumnsString(s)
+}
+
+// ColumnsErr is like Columns but returns an error wrapping ErrInvalidDest if s
+// is not a struct.
+func ColumnsErr(s interface{}) ([]string, error) {
+	return std.ColumnsErr(s)
+}
+
+// MustColumns is like ColumnsErr but panics if s is not a struct.
+func MustColumns(s interface{}) []string {
+	return std.MustColumns(s)
+}
+
+// Values returns the values of the fields of s, a struct or pointer to struct,
+// in the same order as Columns returns their names. It returns nil if s is not
+// a struct. This allows building INSERT statements such as
+//
+//	db.Exec("INSERT INTO t (f1, f2) VALUES (?, ?)", sqlstruct.Values(t)...)
+func Values(s interface{}) []interface{} {
+	return std.Values(s)
+}
+
+// ValuesErr is like Values but returns an error wrapping ErrInvalidDest if s
+// is not a struct.
+func ValuesErr(s interface{}) ([]interface{}, error) {
+	return std.ValuesErr(s)
+}
+
+// MustValues is like ValuesErr but panics if s is not a struct.
+func MustValues(s interface{}) []interface{} {
+	return std.MustValues(s)
+}
+
+// Placeholders returns one "?" placeholder per column of s, separated by
+// commas, so that it lines up with Columns and Values.
+func Placeholders(s interface{}) string {
+	return std.Placeholders(s)
+}
+
+// StrictScan is like Scan but returns an error listing every result column
+// that is not mapped to a struct field, instead of discarding them.
+func StrictScan(dest interface{}, rows Rows) error {
+	return std.Scan(dest, rows, Strict())
 }
 
 func MustScan(dest interface{}, rows Rows) {
-	if err := Scan(dest, rows); err != nil {
-		panic(err)
-	}
+	std.MustScan(dest, rows)
+}
+
+// ScanAll scans all remaining rows in to the slice pointed to by dest, which
+// must be a pointer to a slice of structs or of pointers to structs. A new element
+// is allocated and appended for every row; an empty result set leaves the slice
+// empty. The error returned by rows.Err is returned once iteration is done.
+func ScanAll(dest interface{}, rows Iterator, opts ...ScanOption) error {
+	return std.ScanAll(dest, rows, opts...)
+}
+
+// ScanAllContext is like ScanAll but stops with ctx's error once ctx is done.
+func ScanAllContext(ctx context.Context, dest interface{}, rows Iterator, opts ...ScanOption) error {
+	return std.ScanAllContext(ctx, dest, rows, opts...)
+}
+
+// ScanAllClose is like ScanAll but always closes rows, so that callers cannot
+// forget to. See Session.ScanAllClose for the precedence of returned errors.
+func ScanAllClose(dest interface{}, rows RowsCloser, opts ...ScanOption) error {
+	return std.ScanAllClose(dest, rows, opts...)
 }
@@ -4,8 +4,17 @@
 package sqlstruct
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type testType struct {
@@ -30,6 +39,18 @@ func (r testRows) Scan(dest ...interface{}) error {
 		switch dest[i].(type) {
 		case *string:
 			*(dest[i].(*string)) = r.values[i].(string)
+		case *int:
+			n, err := strconv.Atoi(r.values[i].(string))
+			if err != nil {
+				return err
+			}
+			*(dest[i].(*int)) = n
+		case *interface{}:
+			*(dest[i].(*interface{})) = r.values[i]
+		case sql.Scanner:
+			if err := dest[i].(sql.Scanner).Scan(r.values[i]); err != nil {
+				return err
+			}
 		default:
 			// Do nothing. We assume the tests only use strings here
 		}
@@ -48,12 +69,32 @@ func (r *testRows) addValue(c string, v interface{}) {
 
 func TestColumns(t *testing.T) {
 	var v testType
-	e := "field_a, field_c"
+	e := []string{
+		`"testType"."FieldA" as "field_a"`,
+		`"testType"."FieldB"`,
+		`"testType"."FieldC" as "field_c"`,
+	}
 	c := Columns(v)
 
-	if c != e {
+	if !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	// Pointers to structs produce identical output
+	if c := Columns(&v); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	s := NewSession()
+	if c := s.Columns(&v); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c := s.Columns(v); !reflect.DeepEqual(c, e) {
 		t.Errorf("expected %q got %q", e, c)
 	}
+	if n := len(s.finfos); n != 1 {
+		t.Errorf("expected a single cache entry got %d", n)
+	}
 }
 
 func TestScan(t *testing.T) {
@@ -74,3 +115,2952 @@ func TestScan(t *testing.T) {
 		t.Errorf("expected %q got %q", e, r)
 	}
 }
+
+// testResult is a mock version of sql.Rows holding several rows of strings
+type testResult struct {
+	columns  []string
+	rows     [][]interface{}
+	pos      int
+	err      error // returned by Err
+	closeErr error // returned by Close
+	closed   bool
+}
+
+func (r *testResult) Next() bool {
+	r.pos++
+	return r.pos <= len(r.rows)
+}
+
+func (r *testResult) Err() error {
+	return r.err
+}
+
+func (r *testResult) Close() error {
+	r.closed = true
+	return r.closeErr
+}
+
+func (r *testResult) Scan(dest ...interface{}) error {
+	return testRows{r.columns, r.rows[r.pos-1]}.Scan(dest...)
+}
+
+func (r *testResult) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+func TestScanAll(t *testing.T) {
+	rows := &testResult{
+		columns: []string{"field_a", "field_b", "field_c"},
+		rows: [][]interface{}{
+			{"a1", "b1", "c1"},
+			{"a2", "b2", "c2"},
+		},
+	}
+
+	e := []testType{{"a1", "", "c1"}, {"a2", "", "c2"}}
+
+	var r []testType
+	if err := ScanAll(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	rows.pos = 0
+	var rp []*testType
+	if err := NewSession().ScanAll(&rp, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rp) != len(e) {
+		t.Fatalf("expected %d rows got %d", len(e), len(rp))
+	}
+	for i := range e {
+		if *rp[i] != e[i] {
+			t.Errorf("expected %q got %q", e[i], *rp[i])
+		}
+	}
+}
+
+// cancelResult cancels a context once its first row has been read.
+type cancelResult struct {
+	*testResult
+	cancel func()
+}
+
+func (r cancelResult) Next() bool {
+	ok := r.testResult.Next()
+	if r.pos == 1 {
+		r.cancel()
+	}
+	return ok
+}
+
+func TestScanAllContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rows := cancelResult{&testResult{
+		columns: []string{"field_a"},
+		rows:    [][]interface{}{{"a1"}, {"a2"}, {"a3"}},
+	}, cancel}
+
+	var r []testType
+	if err := ScanAllContext(ctx, &r, rows); err != context.Canceled {
+		t.Fatalf("expected context.Canceled got %v", err)
+	}
+	if e := []testType{{FieldA: "a1"}}; !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %v got %v", e, r)
+	}
+}
+
+func TestScanAllEmpty(t *testing.T) {
+	rows := &testResult{columns: []string{"field_a"}}
+
+	var r []testType
+	if err := ScanAll(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r == nil || len(r) != 0 {
+		t.Errorf("expected empty slice got %#v", r)
+	}
+}
+
+func TestSessionConcurrentScan(t *testing.T) {
+	s := NewSession()
+	e := testType{"a", "", "c"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows := testRows{}
+			rows.addValue("field_a", "a")
+			rows.addValue("field_c", "c")
+
+			var r testType
+			if err := s.Scan(&r, rows); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if r != e {
+				t.Errorf("expected %q got %q", e, r)
+			}
+			s.Columns(r)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestScanInvalidDest(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+
+	var r testType
+	var nilp *testType
+	for _, dest := range []interface{}{nil, r, nilp, new(string)} {
+		if err := Scan(dest, rows); !errors.Is(err, ErrInvalidDest) {
+			t.Errorf("Scan(%T): expected ErrInvalidDest got %v", dest, err)
+		}
+		if err := NewSession().Scan(dest, rows); !errors.Is(err, ErrInvalidDest) {
+			t.Errorf("Session.Scan(%T): expected ErrInvalidDest got %v", dest, err)
+		}
+	}
+
+	if _, err := ColumnsErr(42); !errors.Is(err, ErrInvalidDest) {
+		t.Errorf("expected ErrInvalidDest got %v", err)
+	}
+	if c := Columns(42); c != nil {
+		t.Errorf("expected nil columns got %q", c)
+	}
+}
+
+// testLogger records everything logged through it
+type testLogger []string
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestSessionLogger(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_x", "x")
+
+	var l testLogger
+	s := NewSession()
+	s.Logger = &l
+
+	var r testType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(l) != 1 || l[0] != "sqlstruct: no field for field_x" {
+		t.Errorf("unexpected log output %q", l)
+	}
+}
+
+func TestStrictScan(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("foo", "x")
+	rows.addValue("field_c", "c")
+	rows.addValue("bar", "y")
+
+	var r testType
+	err := StrictScan(&r, rows)
+	if err == nil || err.Error() != `sqlstruct: unmapped columns "foo", "bar"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s := NewSession()
+	s.Strict = true
+	if err := s.Scan(&r, rows); err == nil {
+		t.Errorf("expected error for unmapped columns")
+	}
+
+	rows = testRows{}
+	rows.addValue("field_a", "a")
+	if err := s.Scan(&r, rows); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+type testDBType struct {
+	FieldA string `db:"field_a"`
+	FieldB string `sql:"field_b"`
+}
+
+func TestSessionTagName(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_b", "b")
+
+	s := NewSession()
+	s.TagName = "db"
+
+	var r testDBType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testDBType{"a", ""}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	// The default session must not reuse the "db" field info.
+	r = testDBType{}
+	if err := NewSession().Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testDBType{"", "b"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+}
+
+func TestNameMappers(t *testing.T) {
+	tests := []struct {
+		mapper func(string) string
+		in, e  string
+	}{
+		{SnakeCase, "CreatedAt", "created_at"},
+		{SnakeCase, "UserID", "user_id"},
+		{SnakeCase, "HTTPServer", "http_server"},
+		{SnakeCase, "ID", "id"},
+		{SnakeCase, "Field1", "field1"},
+		{CamelCase, "CreatedAt", "createdAt"},
+		{CamelCase, "ID", "id"},
+		{CamelCase, "HTTPServer", "httpServer"},
+		{Identity, "CreatedAt", "CreatedAt"},
+	}
+	for _, tt := range tests {
+		if r := tt.mapper(tt.in); r != tt.e {
+			t.Errorf("%s: expected %q got %q", tt.in, tt.e, r)
+		}
+	}
+}
+
+type testMappedType struct {
+	CreatedAt string
+	UserName  string `sql:"login"`
+}
+
+func TestSessionNameMapper(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("created_at", "now")
+	rows.addValue("login", "bob")
+
+	s := NewSession()
+	s.NameMapper = SnakeCase
+
+	var r testMappedType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testMappedType{"now", "bob"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	e := []string{
+		`"testMappedType"."CreatedAt" as "created_at"`,
+		`"testMappedType"."UserName" as "login"`,
+	}
+	if c := s.Columns(r); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+}
+
+// testList is a sql.Scanner parsing a comma-separated string
+type testList []string
+
+func (l *testList) Scan(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("unsupported type %T", src)
+	}
+	*l = strings.Split(s, ",")
+	return nil
+}
+
+type testScannerType struct {
+	Name string   `sql:"name"`
+	Tags testList `sql:"tags"`
+}
+
+func TestScanScanner(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("tags", "a,b,c")
+
+	var r testScannerType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := testScannerType{"n", testList{"a", "b", "c"}}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+}
+
+// Embedded pointer types must be exported to be followed
+type TestAddress struct {
+	City string `sql:"city"`
+}
+
+type TestLocation struct {
+	*TestAddress
+	Zone string `sql:"zone"`
+}
+
+type testPerson struct {
+	Name string `sql:"name"`
+	*TestLocation
+}
+
+func TestScanNilEmbeddedPointer(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("city", "c")
+
+	var r testPerson
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.Name != "n" || r.TestLocation == nil || r.TestAddress == nil || r.City != "c" {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestValues(t *testing.T) {
+	v := testType{"a", "b", "c"}
+	e := []interface{}{"a", "b", "c"}
+
+	if r := Values(v); !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+	if r := NewSession().Values(&v); !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+	if r := Values(42); r != nil {
+		t.Errorf("expected nil got %q", r)
+	}
+
+	// Fields behind a nil embedded pointer have nil values
+	p := testPerson{Name: "n"}
+	if c, r := Columns(p), Values(p); len(c) != len(r) || r[0] != "n" || r[1] != nil {
+		t.Errorf("unexpected values %q for columns %q", r, c)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	if r, e := Placeholders(testType{}), "?, ?, ?"; r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+	if r, e := PlaceholdersN(3, Postgres), "$1, $2, $3"; r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+	if r, e := PlaceholdersN(2, MySQL), "?, ?"; r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+	if r := PlaceholdersN(0, Postgres); r != "" {
+		t.Errorf("expected empty string got %q", r)
+	}
+}
+
+type testOmitType struct {
+	ID     int64  `sql:"id,omitempty"`
+	Name   string `sql:"name"`
+	Status string `sql:"status,omitempty"`
+}
+
+func TestInsertColumns(t *testing.T) {
+	s := NewSession()
+
+	names, vals := s.InsertColumns(testOmitType{Name: "n"})
+	if e := []string{`"name"`}; !reflect.DeepEqual(names, e) {
+		t.Errorf("expected %q got %q", e, names)
+	}
+	if e := []interface{}{"n"}; !reflect.DeepEqual(vals, e) {
+		t.Errorf("expected %q got %q", e, vals)
+	}
+
+	names, vals = s.InsertColumns(&testOmitType{1, "", "active"})
+	if e := []string{`"id"`, `"name"`, `"status"`}; !reflect.DeepEqual(names, e) {
+		t.Errorf("expected %q got %q", e, names)
+	}
+	if e := []interface{}{int64(1), "", "active"}; !reflect.DeepEqual(vals, e) {
+		t.Errorf("expected %v got %v", e, vals)
+	}
+
+	// Columns is not value-aware and lists every column
+	if c := s.Columns(testOmitType{}); len(c) != 3 {
+		t.Errorf("expected 3 columns got %q", c)
+	}
+}
+
+type testReadonlyType struct {
+	ID   int64  `sql:"id,readonly"`
+	Name string `sql:"name"`
+}
+
+func TestWritableColumns(t *testing.T) {
+	s := NewSession()
+	v := testReadonlyType{1, "n"}
+
+	e := []string{`"testReadonlyType"."ID" as "id"`, `"testReadonlyType"."Name" as "name"`}
+	if c := s.Columns(v); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c, e := s.WritableColumns(v), []string{`"name"`}; !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c, _ := s.InsertColumns(v); !reflect.DeepEqual(c, []string{`"name"`}) {
+		t.Errorf("expected readonly column to be left out, got %q", c)
+	}
+}
+
+type testPKType struct {
+	ID   int64  `sql:"id,pk,readonly"`
+	Name string `sql:"name"`
+}
+
+type testMultiPKType struct {
+	A int64 `sql:"a,pk"`
+	B int64 `sql:"b,pk"`
+}
+
+func TestPrimaryKey(t *testing.T) {
+	s := NewSession()
+
+	name, val, err := s.PrimaryKey(testPKType{42, "n"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if name != `"id"` || val != int64(42) {
+		t.Errorf("unexpected primary key %s = %v", name, val)
+	}
+
+	if _, _, err := s.PrimaryKey(testType{}); err != ErrNoPrimaryKey {
+		t.Errorf("expected ErrNoPrimaryKey got %v", err)
+	}
+	if _, _, err := s.PrimaryKey(testMultiPKType{}); err == nil {
+		t.Errorf("expected error for multiple pk fields")
+	}
+}
+
+func TestColumnsUnqualified(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+
+	e := []string{`"FieldA" as "field_a"`, `"FieldB"`, `"FieldC" as "field_c"`}
+	if c := s.Columns(testType{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+}
+
+func TestColumnsString(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	s.Dialect = MySQL
+
+	e := "`FieldA` as `field_a`, `FieldB`, `FieldC` as `field_c`"
+	if c := s.ColumnsString(testType{}); c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c := s.ColumnsString(1); c != "" {
+		t.Errorf("expected empty string for invalid dest got %q", c)
+	}
+}
+
+func TestColumnsDialect(t *testing.T) {
+	tests := []struct {
+		d Dialect
+		e []string
+	}{
+		{0, []string{`"testReadonlyType"."ID" as "id"`, `"testReadonlyType"."Name" as "name"`}},
+		{Postgres, []string{`"testReadonlyType"."ID" as "id"`, `"testReadonlyType"."Name" as "name"`}},
+		{MySQL, []string{"`testReadonlyType`.`ID` as `id`", "`testReadonlyType`.`Name` as `name`"}},
+		{Unquoted, []string{"testReadonlyType.ID as id", "testReadonlyType.Name as name"}},
+	}
+	for _, tt := range tests {
+		s := NewSession()
+		s.Dialect = tt.d
+		if c := s.Columns(testReadonlyType{}); !reflect.DeepEqual(c, tt.e) {
+			t.Errorf("dialect %d: expected %q got %q", tt.d, tt.e, c)
+		}
+	}
+
+	if q := MySQL.Quote("a`b"); q != "`a``b`" {
+		t.Errorf("unexpected quoting %s", q)
+	}
+}
+
+type testTableType struct {
+	ID int64 `sql:"id"`
+}
+
+func (testTableType) TableName() string { return "items" }
+
+func TestColumnsTableName(t *testing.T) {
+	s := NewSession()
+	if c, e := s.Columns(testTableType{}), []string{`"items"."ID" as "id"`}; !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	s.TableName(&testTableType{}, "products")
+	s.TableName(testReadonlyType{}, "users")
+	if c, e := s.Columns(testTableType{}), []string{`"products"."ID" as "id"`}; !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	e := []string{`"users"."ID" as "id"`, `"users"."Name" as "name"`}
+	if c := s.Columns(testReadonlyType{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	// Without an override the type name is used
+	if c, e := NewSession().Columns(testOmitType{}), `"testOmitType"."ID" as "id"`; c[0] != e {
+		t.Errorf("expected %q got %q", e, c[0])
+	}
+}
+
+func BenchmarkSessionScan(b *testing.B) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_b", "b")
+	rows.addValue("field_c", "c")
+
+	s := NewSession()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// 10k rows per iteration
+		for j := 0; j < 10000; j++ {
+			var r testType
+			if err := s.Scan(&r, rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkScanAll(b *testing.B) {
+	rows := &testResult{columns: []string{"field_a", "field_b", "field_c"}}
+	for i := 0; i < 100000; i++ {
+		rows.rows = append(rows.rows, []interface{}{"a", "b", "c"})
+	}
+
+	s := NewSession()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rows.pos = 0
+		var r []testType
+		if err := s.ScanAll(&r, rows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_c", "c")
+	rows.addValue("field_x", "x")
+	rows.addValue("field_a", "a")
+
+	s := NewSession()
+	p, err := s.Plan((*testType)(nil), rows.columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var r testType
+			if err := p.Scan(&r, rows); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if e := (testType{"a", "", "c"}); r != e {
+				t.Errorf("expected %q got %q", e, r)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var other testReadonlyType
+	if err := p.Scan(&other, rows); !errors.Is(err, ErrInvalidDest) {
+		t.Errorf("expected ErrInvalidDest got %v", err)
+	}
+
+	s.Strict = true
+	if _, err := s.Plan(testType{}, rows.columns); err == nil {
+		t.Errorf("expected error for unmapped column")
+	}
+}
+
+func TestScanMap(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("id", int64(1))
+	rows.addValue("name", []byte("n"))
+	rows.addValue("score", 1.5)
+	rows.addValue("deleted", nil)
+
+	m, err := ScanMap(rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := map[string]interface{}{"id": int64(1), "name": "n", "score": 1.5, "deleted": nil}
+	if !reflect.DeepEqual(m, e) {
+		t.Errorf("expected %v got %v", e, m)
+	}
+}
+
+func TestScanMapSlice(t *testing.T) {
+	rows := &testResult{
+		columns: []string{"id", "name"},
+		rows: [][]interface{}{
+			{int64(1), []byte("a")},
+			{int64(2), "b"},
+		},
+	}
+	maps, err := ScanMapSlice(rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := []map[string]interface{}{{"id": int64(1), "name": "a"}, {"id": int64(2), "name": "b"}}
+	if !reflect.DeepEqual(maps, e) {
+		t.Errorf("expected %v got %v", e, maps)
+	}
+
+	rows = &testResult{columns: []string{"id"}, err: errors.New("broken")}
+	if _, err := ScanMapSlice(rows); err != rows.err {
+		t.Errorf("expected rows.Err got %v", err)
+	}
+}
+
+func TestScanDuplicateColumns(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a1")
+	rows.addValue("field_c", "c")
+	rows.addValue("field_a", "a2")
+
+	var r testType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testType{"a2", "", "c"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	if err := StrictScan(&r, rows); err == nil || err.Error() != `sqlstruct: duplicate column "field_a"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type TestUser struct {
+	ID   string `sql:"id"`
+	Name string `sql:"name"`
+}
+
+type TestOrg struct {
+	ID   string `sql:"id"`
+	Name string `sql:"name"`
+}
+
+type testMembership struct {
+	TestUser `sql:"user_,prefix"`
+	TestOrg  `sql:"org_,prefix"`
+	Role     string `sql:"role"`
+}
+
+func TestScanPrefix(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("user_id", "u1")
+	rows.addValue("user_name", "bob")
+	rows.addValue("org_id", "o1")
+	rows.addValue("org_name", "acme")
+	rows.addValue("role", "admin")
+
+	var r testMembership
+	if err := StrictScan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := testMembership{TestUser{"u1", "bob"}, TestOrg{"o1", "acme"}, "admin"}
+	if r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	c := Columns(r)
+	if len(c) != 5 || c[0] != `"TestUser"."ID" as "user_id"` || c[2] != `"TestOrg"."ID" as "org_id"` {
+		t.Errorf("unexpected columns %q", c)
+	}
+}
+
+func TestSessionIncludeUntagged(t *testing.T) {
+	s := NewSession()
+	s.IncludeUntagged = false
+
+	e := []string{`"testType"."FieldA" as "field_a"`, `"testType"."FieldC" as "field_c"`}
+	if c := s.Columns(testType{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	// Untagged embedded structs are still explored
+	if c := s.Columns(testPerson{}); len(c) != 3 {
+		t.Errorf("expected 3 columns got %q", c)
+	}
+
+	if c := NewSession().Columns(testType{}); len(c) != 3 {
+		t.Errorf("expected untagged field by default, got %q", c)
+	}
+}
+
+// testRecordRows is a testRows remembering the destinations of its last Scan
+type testRecordRows struct {
+	testRows
+	dest []interface{}
+}
+
+func (r *testRecordRows) Scan(dest ...interface{}) error {
+	r.dest = dest
+	return r.testRows.Scan(dest...)
+}
+
+func TestScanUnmappedNoAlias(t *testing.T) {
+	rows := &testRecordRows{}
+	rows.addValue("x", []byte("x"))
+	rows.addValue("field_a", "a")
+	rows.addValue("y", []byte("y"))
+
+	var r testType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	x, y := rows.dest[0], rows.dest[2]
+	if _, ok := x.(*sql.RawBytes); ok {
+		t.Errorf("unmapped column scanned in to retained RawBytes")
+	}
+	if reflect.ValueOf(x).Kind() == reflect.Ptr && x == y {
+		t.Errorf("unmapped columns share destination %p", x)
+	}
+	if r.FieldA != "a" {
+		t.Errorf("expected %q got %q", "a", r.FieldA)
+	}
+}
+
+func TestMustVariants(t *testing.T) {
+	v := testType{"a", "b", "c"}
+	if c, e := MustColumns(v), Columns(v); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if r, e := MustValues(&v), Values(v); !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	for _, f := range []func(){
+		func() { MustColumns(42) },
+		func() { MustValues(nil) },
+		func() { NewSession().MustColumns("x") },
+		func() { NewSession().MustValues(42) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic")
+				} else if err, ok := r.(error); !ok || !errors.Is(err, ErrInvalidDest) {
+					t.Errorf("expected ErrInvalidDest panic got %v", r)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+type TestAudit struct {
+	CreatedAt string `sql:"created_at"`
+}
+
+type testAuditedUser struct {
+	Name string `sql:"name"`
+	TestAudit
+}
+
+func TestColumnsEmbeddedContext(t *testing.T) {
+	e := []string{
+		`"testAuditedUser"."Name" as "name"`,
+		`"testAuditedUser"."CreatedAt" as "created_at"`,
+	}
+	if c := Columns(testAuditedUser{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	s := NewSession()
+	s.TableName(testAuditedUser{}, "users")
+	e = []string{`"users"."Name" as "name"`, `"users"."CreatedAt" as "created_at"`}
+	if c := s.Columns(testAuditedUser{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+}
+
+type testCaseType struct {
+	UserName string `sql:"UserName"`
+	Email    string `sql:"EMAIL"`
+}
+
+func TestSessionCaseInsensitive(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("username", "bob")
+	rows.addValue("Email", "bob@example.com")
+
+	s := NewSession()
+	s.CaseInsensitive = true
+	s.Strict = true
+
+	var r testCaseType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testCaseType{"bob", "bob@example.com"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	e := []string{`"testCaseType"."UserName"`, `"testCaseType"."Email" as "EMAIL"`}
+	if c := s.Columns(r); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	if err := StrictScan(&r, rows); err == nil {
+		t.Errorf("expected case sensitive match to fail")
+	}
+}
+
+func TestWhere(t *testing.T) {
+	s := NewSession()
+	clause, args := s.Where(testType{FieldA: "a", FieldC: "c"})
+	if e := `"field_a" = ? AND "field_c" = ?`; clause != e {
+		t.Errorf("expected %q got %q", e, clause)
+	}
+	if e := []interface{}{"a", "c"}; !reflect.DeepEqual(args, e) {
+		t.Errorf("expected %q got %q", e, args)
+	}
+
+	s.Dialect = Postgres
+	clause, _ = s.Where(&testType{FieldB: "b", FieldC: "c"})
+	if e := `"FieldB" = $1 AND "field_c" = $2`; clause != e {
+		t.Errorf("expected %q got %q", e, clause)
+	}
+
+	if clause, args := s.Where(testType{}); clause != "" || args != nil {
+		t.Errorf("expected empty clause got %q %q", clause, args)
+	}
+}
+
+type testMeta struct {
+	Color string `json:"color"`
+	Size  int    `json:"size"`
+}
+
+type testJSONType struct {
+	Name  string                 `sql:"name"`
+	Meta  testMeta               `sql:"meta,json"`
+	Attrs map[string]interface{} `sql:"attrs,json"`
+}
+
+type testUpdateType struct {
+	ID      int64  `sql:"id,pk"`
+	Created string `sql:"created,readonly"`
+	Name    string `sql:"name"`
+	Email   string `sql:"email"`
+}
+
+func TestUpdateSet(t *testing.T) {
+	s := NewSession()
+	d := testUpdateType{ID: 1, Created: "now", Name: "n"}
+
+	set, args := s.UpdateSet(d)
+	if e := `"name" = ?, "email" = ?`; set != e {
+		t.Errorf("expected %q got %q", e, set)
+	}
+	if e := []interface{}{"n", ""}; !reflect.DeepEqual(args, e) {
+		t.Errorf("expected %v got %v", e, args)
+	}
+
+	s.Dialect = Postgres
+	set, args = s.UpdateSet(&testType{FieldA: "a", FieldB: "b"}, "field_c", "missing", "field_a")
+	if e := `"field_c" = $1, "field_a" = $2`; set != e {
+		t.Errorf("expected %q got %q", e, set)
+	}
+	if e := []interface{}{"", "a"}; !reflect.DeepEqual(args, e) {
+		t.Errorf("expected %v got %v", e, args)
+	}
+
+	if set, args := s.UpdateSet(1); set != "" || args != nil {
+		t.Errorf("expected empty clause got %q %v", set, args)
+	}
+}
+
+func TestSelectStmt(t *testing.T) {
+	s := NewSession()
+	e := `SELECT "testType"."FieldA" as "field_a", "testType"."FieldB", "testType"."FieldC" as "field_c" FROM "testType"`
+	if got := s.SelectStmt(testType{}, `"testType"`, ""); got != e {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	s.Qualify = false
+	s.Dialect = MySQL
+	e = "SELECT `FieldA` as `field_a`, `FieldB`, `FieldC` as `field_c` FROM t WHERE field_a = ?"
+	if got := s.SelectStmt(&testType{}, "t", "field_a = ?"); got != e {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	if got := s.SelectStmt(1, "t", ""); got != "" {
+		t.Errorf("expected empty statement got %q", got)
+	}
+}
+
+func TestScanJSON(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("meta", []byte(`{"color":"red","size":3}`))
+	rows.addValue("attrs", `{"a":1,"b":"x"}`)
+
+	var r testJSONType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := testJSONType{"n", testMeta{"red", 3}, map[string]interface{}{"a": 1.0, "b": "x"}}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %v got %v", e, r)
+	}
+
+	rows = testRows{}
+	rows.addValue("meta", []byte(`{`))
+	if err := Scan(&r, rows); err == nil {
+		t.Errorf("expected error for malformed JSON")
+	}
+}
+
+func TestScanAllClose(t *testing.T) {
+	newRows := func() *testResult {
+		return &testResult{
+			columns: []string{"field_a"},
+			rows:    [][]interface{}{{"a1"}, {"a2"}},
+		}
+	}
+
+	rows := newRows()
+	var r []testType
+	if err := ScanAllClose(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(r) != 2 || !rows.closed {
+		t.Errorf("unexpected result %q, closed: %t", r, rows.closed)
+	}
+
+	errClose, errIter := errors.New("close"), errors.New("iter")
+
+	rows = newRows()
+	rows.closeErr, rows.err = errClose, errIter
+	if err := ScanAllClose(&r, rows); err != errClose {
+		t.Errorf("expected close error got %v", err)
+	}
+
+	rows = newRows()
+	rows.err = errIter
+	if err := ScanAllClose(&r, rows); err != errIter {
+		t.Errorf("expected iteration error got %v", err)
+	}
+
+	rows = newRows()
+	rows.closeErr = errClose
+	if err := ScanAllClose(r, rows); !errors.Is(err, ErrInvalidDest) || !rows.closed {
+		t.Errorf("expected ErrInvalidDest and closed rows, got %v", err)
+	}
+}
+
+func TestSessionFields(t *testing.T) {
+	e := []FieldInfo{
+		{"field_a", "FieldA", true, reflect.TypeOf("")},
+		{"FieldB", "FieldB", false, reflect.TypeOf("")},
+		{"field_c", "FieldC", true, reflect.TypeOf("")},
+	}
+	if f := NewSession().Fields(&testType{}); !reflect.DeepEqual(f, e) {
+		t.Errorf("expected %v got %v", e, f)
+	}
+
+	// Pointer fields keep their declared type.
+	var p struct {
+		N *int64 `sql:"n"`
+	}
+	if f := NewSession().Fields(&p); len(f) != 1 || f[0].Type != reflect.TypeOf(p.N) {
+		t.Errorf("expected a field of type *int64 got %v", f)
+	}
+}
+
+type testDefaultType struct {
+	Name   string `sql:"name"`
+	Status string `sql:"status,default=active"`
+	Level  int    `sql:"level,default=3"`
+}
+
+func TestScanDefault(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+
+	var r testDefaultType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testDefaultType{"n", "active", 3}); r != e {
+		t.Errorf("expected %v got %v", e, r)
+	}
+
+	// Present columns take precedence over defaults
+	rows.addValue("status", "banned")
+	r = testDefaultType{}
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testDefaultType{"n", "banned", 3}); r != e {
+		t.Errorf("expected %v got %v", e, r)
+	}
+}
+
+func TestTagOptions(t *testing.T) {
+	name, opts := parseTag("status,readonly,default=active,time=2006-01-02,empty=")
+	if name != "status" {
+		t.Errorf("expected name %q got %q", "status", name)
+	}
+
+	if !opts.contains("readonly") || opts.contains("default") || opts.contains("pk") {
+		t.Errorf("unexpected flags in %q", opts)
+	}
+
+	tests := []struct {
+		key, e string
+		ok     bool
+	}{
+		{"default", "active", true},
+		{"time", "2006-01-02", true},
+		{"empty", "", true},
+		{"readonly", "", false},
+		{"def", "", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		if v, ok := opts.get(tt.key); v != tt.e || ok != tt.ok {
+			t.Errorf("get(%q): expected %q, %t got %q, %t", tt.key, tt.e, tt.ok, v, ok)
+		}
+	}
+
+	if _, ok := tagOptions("").get("default"); ok {
+		t.Errorf("unexpected option in empty options")
+	}
+
+	_, opts = parseTag("email,alias=email_address,readonly,alias=mail")
+	if e, got := []string{"email_address", "mail"}, opts.getAll("alias"); !reflect.DeepEqual(got, e) {
+		t.Errorf("getAll: expected %q got %q", e, got)
+	}
+}
+
+type testTimeType struct {
+	DOB     time.Time  `sql:"dob,time=2006-01-02"`
+	Updated *time.Time `sql:"updated,time=2006-01-02 15:04"`
+}
+
+func TestScanTimeLayout(t *testing.T) {
+	dob := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	// String driver
+	rows := testRows{}
+	rows.addValue("dob", "1990-05-17")
+	rows.addValue("updated", []byte("2020-01-02 03:04"))
+
+	var r testTimeType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !r.DOB.Equal(dob) || r.Updated == nil || !r.Updated.Equal(updated) {
+		t.Errorf("unexpected result %v", r)
+	}
+
+	// Native time driver and NULL
+	rows = testRows{}
+	rows.addValue("dob", dob)
+	rows.addValue("updated", nil)
+
+	r = testTimeType{}
+	if err := Scan(&r, rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !r.DOB.Equal(dob) || r.Updated != nil {
+		t.Errorf("unexpected result %v", r)
+	}
+
+	rows = testRows{}
+	rows.addValue("dob", "17/05/1990")
+	if err := Scan(&r, rows); err == nil {
+		t.Errorf("expected error for malformed time")
+	}
+}
+
+func TestSessionRegister(t *testing.T) {
+	s := NewSession()
+	s.Register(testType{}, (*testOmitType)(nil))
+
+	e := []reflect.Type{reflect.TypeOf(testOmitType{}), reflect.TypeOf(testType{})}
+	if c := s.CachedTypes(); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %v got %v", e, c)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic registering a non-struct")
+		}
+	}()
+	s.Register(42)
+}
+
+func BenchmarkFirstScan(b *testing.B) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("tags", "a,b")
+
+	for _, register := range []bool{false, true} {
+		b.Run(fmt.Sprintf("registered=%t", register), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s := NewSession()
+				if register {
+					s.Register(testScannerType{})
+				}
+				b.StartTimer()
+
+				var r testScannerType
+				if err := s.Scan(&r, rows); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+type testIntType struct {
+	Name string `sql:"name"`
+	Age  int    `sql:"age"`
+}
+
+func TestScanErrorNamesColumn(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("age", "old")
+
+	var r testIntType
+	err := Scan(&r, rows)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	e := `sqlstruct: scanning column "age" in to field Age (int): `
+	if !strings.HasPrefix(err.Error(), e) {
+		t.Errorf("expected error starting with %q got %q", e, err)
+	}
+	var nerr *strconv.NumError
+	if !errors.As(err, &nerr) {
+		t.Errorf("expected wrapped driver error got %v", err)
+	}
+}
+
+type testNullType struct {
+	Name  *string    `sql:"name"`
+	Count *int64     `sql:"count"`
+	OK    *bool      `sql:"ok"`
+	At    *time.Time `sql:"at"`
+}
+
+func TestScanNullPointers(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("count", int64(3))
+	rows.addValue("ok", true)
+	rows.addValue("at", at)
+
+	var r testNullType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name == nil || *r.Name != "n" {
+		t.Errorf("expected name %q got %v", "n", r.Name)
+	}
+	if r.Count == nil || *r.Count != 3 {
+		t.Errorf("expected count 3 got %v", r.Count)
+	}
+	if r.OK == nil || !*r.OK {
+		t.Errorf("expected ok true got %v", r.OK)
+	}
+	if r.At == nil || !r.At.Equal(at) {
+		t.Errorf("expected at %v got %v", at, r.At)
+	}
+
+	nulls := testRows{}
+	nulls.addValue("name", nil)
+	nulls.addValue("count", nil)
+	nulls.addValue("ok", nil)
+	nulls.addValue("at", nil)
+	if err := Scan(&r, nulls); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != nil || r.Count != nil || r.OK != nil || r.At != nil {
+		t.Errorf("expected nil fields got %+v", r)
+	}
+}
+
+func TestColumnsExcept(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	got := s.ColumnsExcept(testType{}, "field_a", "FieldC", "missing")
+	e := []string{`"FieldB"`, `"FieldC" as "field_c"`}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	if got := s.ColumnsExcept(1, "field_a"); got != nil {
+		t.Errorf("expected nil for invalid dest got %q", got)
+	}
+}
+
+func TestColumnsOnly(t *testing.T) {
+	l := &testLogger{}
+	s := NewSession()
+	s.Qualify = false
+	s.Logger = l
+	got := s.ColumnsOnly(testType{}, "field_c", "missing", "field_a")
+	e := []string{`"FieldC" as "field_c"`, `"FieldA" as "field_a"`}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	if len(*l) != 1 || !strings.Contains((*l)[0], "missing") {
+		t.Errorf("expected the unknown column to be logged got %q", *l)
+	}
+}
+
+func TestColumnsWithPrefix(t *testing.T) {
+	s := NewSession()
+	e := []string{`"u"."FieldA" as "u_field_a"`, `"u"."FieldB" as "u_FieldB"`, `"u"."FieldC" as "u_field_c"`}
+	if got := s.ColumnsWithPrefix(testType{}, "u", "u_"); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	e = []string{`"FieldA" as "field_a"`, `"FieldB"`, `"FieldC" as "field_c"`}
+	if got := s.ColumnsWithPrefix(testType{}, "", ""); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+}
+
+func TestScanWithPrefix(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("u_field_a", "ua")
+	rows.addValue("u_field_c", "uc")
+	rows.addValue("m_field_a", "ma")
+	rows.addValue("m_field_c", "mc")
+
+	s := NewSession()
+	s.Strict = true
+	var u, m testType
+	if err := s.ScanWithPrefix(&u, rows, "u_"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ScanWithPrefix(&m, rows, "m_"); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "ua", FieldC: "uc"}); u != e {
+		t.Errorf("expected %+v got %+v", e, u)
+	}
+	if e := (testType{FieldA: "ma", FieldC: "mc"}); m != e {
+		t.Errorf("expected %+v got %+v", e, m)
+	}
+}
+
+type testUnsupportedType struct {
+	Name string       `sql:"name"`
+	Done chan bool    `sql:"done"`
+	Fn   func()       `sql:"fn"`
+	Z    complex128   `sql:"z"`
+	Ch   chan<- error // untagged, skipped silently
+}
+
+func TestUnsupportedFieldKinds(t *testing.T) {
+	l := &testLogger{}
+	s := NewSession()
+	s.Qualify = false
+	s.Logger = l
+
+	e := []string{`"Name" as "name"`}
+	if got := s.Columns(testUnsupportedType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	if len(*l) != 3 {
+		t.Fatalf("expected 3 skipped fields to be logged got %q", *l)
+	}
+	if !strings.Contains((*l)[0], "Done") {
+		t.Errorf("expected log to name the field got %q", (*l)[0])
+	}
+}
+
+type testNullSrcType struct {
+	Nick      string         `sql:"-"`
+	NickNull  sql.NullString `sql:"nick,nullsrc=Nick"`
+	Count     int32          `sql:"-"`
+	CountNull sql.NullInt32  `sql:"count,nullsrc=Count"`
+}
+
+func TestScanInto(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("nick", "n")
+	rows.addValue("count", int64(4))
+
+	var r testNullSrcType
+	if err := NewSession().ScanInto(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.Nick != "n" || r.Count != 4 {
+		t.Errorf("expected copied values got %+v", r)
+	}
+
+	nulls := testRows{}
+	nulls.addValue("nick", nil)
+	nulls.addValue("count", nil)
+	if err := NewSession().ScanInto(&r, nulls); err != nil {
+		t.Fatal(err)
+	}
+	if r.Nick != "" || r.Count != 0 {
+		t.Errorf("expected zero values for NULL got %+v", r)
+	}
+
+	var code struct {
+		Code     string        `sql:"-"`
+		CodeNull sql.NullInt64 `sql:"code,nullsrc=Code"`
+	}
+	rows = testRows{}
+	rows.addValue("code", int64(42))
+	if err := NewSession().ScanInto(&code, rows); err == nil {
+		t.Errorf("expected error copying an integer in to a string got %q", code.Code)
+	}
+}
+
+func TestScanReset(t *testing.T) {
+	first := testRows{}
+	first.addValue("field_a", "a")
+	first.addValue("field_c", "c")
+	second := testRows{}
+	second.addValue("field_a", "a2")
+
+	s := NewSession()
+	var r testType
+	if err := s.ScanReset(&r, first); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+	if err := s.ScanReset(&r, second); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a2"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+// TestNullTime is an embeddable sql.Scanner.
+type TestNullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (n *TestNullTime) Scan(src interface{}) error {
+	n.Time, n.Valid = src.(time.Time)
+	return nil
+}
+
+type testEmbeddedScannerType struct {
+	Name         string `sql:"name"`
+	TestNullTime `sql:"deleted_at"`
+}
+
+func TestScanEmbeddedScanner(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	e := []string{`"Name" as "name"`, `"TestNullTime" as "deleted_at"`}
+	if got := s.Columns(testEmbeddedScannerType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("deleted_at", at)
+
+	var r testEmbeddedScannerType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Valid || !r.Time.Equal(at) {
+		t.Errorf("expected deleted_at %v got %+v", at, r.TestNullTime)
+	}
+}
+
+func TestScanStats(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_x", "x")
+	rows.addValue("field_c", "c")
+	rows.addValue("field_a", "a2")
+
+	var r testType
+	matched, discarded, err := NewSession().ScanStats(&r, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched != 2 || discarded != 2 {
+		t.Errorf("expected 2 matched and 2 discarded got %d and %d", matched, discarded)
+	}
+	if e := (testType{FieldA: "a2", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+// testUUID is a fixed size binary type scanned from its hex string form.
+type testUUID [4]byte
+
+type testUUIDScanner struct {
+	dst reflect.Value
+}
+
+func (s *testUUIDScanner) Scan(src interface{}) error {
+	str, ok := src.(string)
+	if !ok || len(str) != 8 {
+		return fmt.Errorf("invalid uuid %v", src)
+	}
+	var u testUUID
+	for i := range u {
+		n, err := strconv.ParseUint(str[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return err
+		}
+		u[i] = byte(n)
+	}
+	s.dst.Set(reflect.ValueOf(u))
+	return nil
+}
+
+type testConverterType struct {
+	ID   testUUID `sql:"id"`
+	Name string   `sql:"name"`
+}
+
+func TestRegisterConverter(t *testing.T) {
+	s := NewSession()
+	s.RegisterConverter(reflect.TypeOf(testUUID{}), func(dst reflect.Value) sql.Scanner {
+		return &testUUIDScanner{dst}
+	})
+
+	rows := testRows{}
+	rows.addValue("id", "0a0b0c0d")
+	rows.addValue("name", "n")
+
+	var r testConverterType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testConverterType{testUUID{10, 11, 12, 13}, "n"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	// Pointer fields are not handed to the converter of their element type.
+	var p struct {
+		ID *testUUID `sql:"id"`
+	}
+	if err := s.Scan(&p, rows); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != nil {
+		t.Errorf("expected no converter for *testUUID got %v", p.ID)
+	}
+	s.RegisterConverter(reflect.TypeOf(p.ID), func(dst reflect.Value) sql.Scanner {
+		dst.Set(reflect.New(dst.Type().Elem()))
+		return &testUUIDScanner{dst.Elem()}
+	})
+	if err := s.Scan(&p, rows); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID == nil || *p.ID != (testUUID{10, 11, 12, 13}) {
+		t.Errorf("expected the *testUUID converter to apply got %v", p.ID)
+	}
+}
+
+func TestScanAnonymousStruct(t *testing.T) {
+	var r struct {
+		A string `sql:"a"`
+		B string `sql:"b"`
+	}
+	e := []string{`"A" as "a"`, `"B" as "b"`}
+	if got := Columns(r); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	rows := testRows{}
+	rows.addValue("a", "x")
+	rows.addValue("b", "y")
+	if err := Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.A != "x" || r.B != "y" {
+		t.Errorf("expected x and y got %+v", r)
+	}
+}
+
+type testAliasType struct {
+	Email string `sql:"email,alias=email_address,alias=mail"`
+	Name  string `sql:"name"`
+}
+
+func TestScanAlias(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("email_address", "old@example.com")
+
+	s := NewSession()
+	s.Strict = true
+	var r testAliasType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testAliasType{"old@example.com", "n"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	// The column name wins over aliases, wherever it appears.
+	rows = testRows{}
+	rows.addValue("mail", "alias@example.com")
+	rows.addValue("email", "new@example.com")
+	r = testAliasType{}
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.Email != "new@example.com" {
+		t.Errorf("expected the column to win over the alias got %q", r.Email)
+	}
+}
+
+type TestAddr struct {
+	City string `sql:"city"`
+	Zip  string `sql:"zip"`
+}
+
+type testDottedType struct {
+	Name string `sql:"name"`
+	TestAddr
+}
+
+func TestScanDottedColumns(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("testaddr.city", "c")
+	rows.addValue("TestAddr.zip", "z")
+
+	s := NewSession()
+	s.Strict = true
+	var r testDottedType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testDottedType{"n", TestAddr{"c", "z"}}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+func TestScanFieldAccessPanic(t *testing.T) {
+	s := NewSession()
+	s.RegisterConverter(reflect.TypeOf(testUUID{}), func(dst reflect.Value) sql.Scanner {
+		dst.Set(reflect.ValueOf("not a uuid")) // panics
+		return nil
+	})
+
+	rows := testRows{}
+	rows.addValue("id", "0a0b0c0d")
+
+	var r testConverterType
+	err := s.Scan(&r, rows)
+	if !errors.Is(err, ErrFieldAccess) {
+		t.Fatalf("expected ErrFieldAccess got %v", err)
+	}
+	if !strings.Contains(err.Error(), "ID (index [0])") {
+		t.Errorf("expected error to name the field got %q", err)
+	}
+}
+
+// testStatus is an enum stored as a string column.
+type testStatus int
+
+const (
+	testStatusActive testStatus = iota + 1
+	testStatusBanned
+)
+
+func (s *testStatus) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "active":
+		*s = testStatusActive
+	case "banned":
+		*s = testStatusBanned
+	default:
+		return fmt.Errorf("invalid status %q", text)
+	}
+	return nil
+}
+
+type testStatusType struct {
+	Name   string     `sql:"name"`
+	Status testStatus `sql:"status"`
+}
+
+func TestScanTextUnmarshaler(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("status", []byte("banned"))
+
+	var r testStatusType
+	if err := Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testStatusType{"n", testStatusBanned}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	rows = testRows{}
+	rows.addValue("status", "unknown")
+	if err := Scan(&r, rows); err == nil || !strings.Contains(err.Error(), `"status"`) {
+		t.Errorf("expected error naming the column got %v", err)
+	}
+}
+
+type TestDupA struct {
+	ID   string `sql:"id"`
+	Name string `sql:"name"`
+	Note string `sql:"note"`
+}
+
+type TestDupB struct {
+	ID   string `sql:"id"`
+	Kind string `sql:"kind"`
+	Note string `sql:"note"`
+}
+
+type testDeterministicType struct {
+	TestDupA
+	*TestDupB
+	Name  string `sql:"name"`
+	Extra string `sql:"extra"`
+}
+
+func TestColumnsDeterministic(t *testing.T) {
+	first := strings.Join(NewSession().Columns(testDeterministicType{}), ", ")
+	for i := 0; i < 1000; i++ {
+		// A new session for each run, so the fields are resolved anew.
+		got := strings.Join(NewSession().Columns(testDeterministicType{}), ", ")
+		if got != first {
+			t.Fatalf("run %d: expected %q got %q", i, first, got)
+		}
+	}
+	e := `"testDeterministicType"."Kind" as "kind", "testDeterministicType"."Name" as "name", "testDeterministicType"."Extra" as "extra"`
+	if first != e {
+		t.Errorf("expected %q got %q", e, first)
+	}
+}
+
+type testRestType struct {
+	Name  string                 `sql:"name"`
+	Extra map[string]interface{} `sql:",rest"`
+}
+
+type testTwoRestType struct {
+	A map[string]interface{} `sql:",rest"`
+	B map[string]interface{} `sql:",rest"`
+}
+
+func TestScanRest(t *testing.T) {
+	s := NewSession()
+	s.Strict = true
+	s.Qualify = false
+	if e, got := []string{`"Name" as "name"`}, s.Columns(testRestType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	rows := testRows{}
+	rows.addValue("color", []byte("red"))
+	rows.addValue("name", "n")
+	rows.addValue("size", int64(3))
+
+	var r testRestType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "n" {
+		t.Errorf("expected name %q got %q", "n", r.Name)
+	}
+	if e := map[string]interface{}{"color": "red", "size": int64(3)}; !reflect.DeepEqual(r.Extra, e) {
+		t.Errorf("expected %v got %v", e, r.Extra)
+	}
+
+	var two testTwoRestType
+	if err := s.Scan(&two, rows); err == nil {
+		t.Errorf("expected error for multiple rest fields")
+	}
+}
+
+type testNumericType struct {
+	Int   int     `sql:"int"`
+	Int64 int64   `sql:"int64"`
+	Float float64 `sql:"float"`
+	Uint  uint64  `sql:"uint"`
+}
+
+func TestParseNumericStrings(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("int", []byte("-1"))
+	rows.addValue("int64", "9007199254740993")
+	rows.addValue("float", []byte("12.50"))
+	rows.addValue("uint", int64(7))
+
+	s := NewSession()
+	s.ParseNumericStrings = true
+	var r testNumericType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testNumericType{-1, 9007199254740993, 12.5, 7}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	rows = testRows{}
+	rows.addValue("int", []byte("1.5"))
+	if err := s.Scan(&r, rows); err == nil || !strings.Contains(err.Error(), `"int"`) {
+		t.Errorf("expected error naming the column got %v", err)
+	}
+
+	// Pointer fields are allocated, and left nil on NULL.
+	var p struct {
+		A *int64 `sql:"a"`
+		B *int64 `sql:"b"`
+	}
+	rows = testRows{}
+	rows.addValue("a", "3")
+	rows.addValue("b", nil)
+	if err := s.Scan(&p, rows); err != nil {
+		t.Fatal(err)
+	}
+	if p.A == nil || *p.A != 3 || p.B != nil {
+		t.Errorf("expected a 3 and a nil b got %v %v", p.A, p.B)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	got := NewSession().Explain(&testDefaultType{}, []string{"name", "extra"})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("expected a header and a line per column got %q", got)
+	}
+	if !strings.HasPrefix(lines[0], "COLUMN") {
+		t.Errorf("expected a header got %q", lines[0])
+	}
+	if f := strings.Fields(lines[1]); len(f) < 4 || f[0] != "name" || f[3] != "[0]" {
+		t.Errorf("expected name to map to field index [0] got %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "extra") || !strings.HasSuffix(lines[2], "discarded") {
+		t.Errorf("expected extra to be discarded got %q", lines[2])
+	}
+	if !strings.Contains(got, "default") {
+		t.Errorf("expected fields set from defaults to be listed got %q", got)
+	}
+
+	if got := NewSession().Explain(1, nil); !strings.Contains(got, "invalid destination") {
+		t.Errorf("expected invalid destination got %q", got)
+	}
+}
+
+func TestSessionColumnMatcher(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("testType.field_a", "a")
+	rows.addValue("field_c", "c")
+
+	s := NewSession()
+	s.Strict = true
+	s.ColumnMatcher = func(col string) string {
+		return strings.TrimPrefix(col, "testType.")
+	}
+	var r testType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+type testMultiTagType struct {
+	A string `sql:"a_sql" db:"a_db"`
+	B string `db:"b_db" json:"b_json"`
+	C string `json:"c_json"`
+	D string `sql:"" db:"d_db"`
+	E string `db:"-" json:"e_json"`
+}
+
+func TestSessionTagNames(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	s.IncludeUntagged = false
+	s.TagNames = []string{"sql", "db", "json"}
+
+	e := []string{`"A" as "a_sql"`, `"B" as "b_db"`, `"C" as "c_json"`, `"D" as "d_db"`}
+	if got := s.Columns(testMultiTagType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	// The cache is keyed by the ordered list.
+	s.TagNames = []string{"json", "db"}
+	e = []string{`"A" as "a_db"`, `"B" as "b_json"`, `"C" as "c_json"`, `"D" as "d_db"`, `"E" as "e_json"`}
+	if got := s.Columns(testMultiTagType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+}
+
+type testNode struct {
+	ID   string    `sql:"id"`
+	Next *testNode `sql:"-"`
+}
+
+type testOpaqueNode struct {
+	ID   string `sql:"id"`
+	Next *testOpaqueNode
+}
+
+type TestTree struct {
+	*TestTree
+	ID string `sql:"id"`
+}
+
+type testPrefixNode struct {
+	Val  int             `sql:"val"`
+	Next *testPrefixNode `sql:"next,prefix"`
+}
+
+type TestPrefixTree struct {
+	*TestPrefixTree `sql:"up,prefix"`
+	ID              string `sql:"id"`
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+
+	if e, got := []string{`"ID" as "id"`}, s.Columns(testNode{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	// An untagged self-referential pointer is a single opaque column.
+	if e, got := []string{`"ID" as "id"`, `"Next"`}, s.Columns(testOpaqueNode{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	// A struct embedding itself is only explored once.
+	if e, got := []string{`"ID" as "id"`}, s.Columns(TestTree{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	// So is a struct tagged "prefix" reached again through itself, its
+	// column being a single opaque one.
+	if e, got := []string{`"Val" as "val"`, `"Next" as "next"`}, s.Columns(testPrefixNode{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	if e, got := []string{`"TestPrefixTree" as "up"`, `"ID" as "id"`}, s.Columns(TestPrefixTree{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	if err := s.Validate(testPrefixNode{}); err == nil {
+		t.Error("expected the opaque next column to be reported")
+	}
+
+	rows := testRows{}
+	rows.addValue("id", "1")
+	var n testNode
+	if err := s.Scan(&n, rows); err != nil {
+		t.Fatal(err)
+	}
+	if n.ID != "1" || n.Next != nil {
+		t.Errorf("expected id 1 and no next got %+v", n)
+	}
+}
+
+type testOrderType struct {
+	Zeta  string `sql:"zeta"`
+	Alpha string `sql:"alpha"`
+	TestAddr
+}
+
+func TestSessionColumnOrder(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	d := testOrderType{"z", "a", TestAddr{"c", "p"}}
+
+	e := []string{`"Zeta" as "zeta"`, `"Alpha" as "alpha"`, `"City" as "city"`, `"Zip" as "zip"`}
+	if got := s.Columns(d); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	s.ColumnOrder = Alphabetical
+	e = []string{`"Alpha" as "alpha"`, `"City" as "city"`, `"Zeta" as "zeta"`, `"Zip" as "zip"`}
+	if got := s.Columns(d); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	if e, got := []interface{}{"a", "c", "z", "p"}, s.Values(d); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected values in column order %v got %v", e, got)
+	}
+}
+
+// testInt64Array scans a Postgres style array literal such as "{1,2}" in to
+// the slice dst points to.
+type testInt64Array struct {
+	dst *[]int64
+}
+
+func (a *testInt64Array) Scan(src interface{}) error {
+	str, ok := src.(string)
+	if !ok || !strings.HasPrefix(str, "{") || !strings.HasSuffix(str, "}") {
+		return fmt.Errorf("invalid array %v", src)
+	}
+	*a.dst = (*a.dst)[:0]
+	for _, e := range strings.Split(str[1:len(str)-1], ",") {
+		n, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return err
+		}
+		*a.dst = append(*a.dst, n)
+	}
+	return nil
+}
+
+type testArrayType struct {
+	Name string  `sql:"name"`
+	IDs  []int64 `sql:"ids"`
+}
+
+func TestRegisterArrayScanner(t *testing.T) {
+	s := NewSession()
+	s.RegisterArrayScanner(reflect.TypeOf(int64(0)), func(dst interface{}) sql.Scanner {
+		return &testInt64Array{dst.(*[]int64)}
+	})
+
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("ids", "{1,2,3}")
+
+	var r testArrayType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := []int64{1, 2, 3}; !reflect.DeepEqual(r.IDs, e) {
+		t.Errorf("expected %v got %v", e, r.IDs)
+	}
+}
+
+type testInvalidTagsType struct {
+	A string `sql:"a,readonly,unique"`
+	B string `sql:"b,default"`
+	C string `sql:"c,=x,pk=1"`
+	D string `sql:"a"`
+	E string `sql:"e,alias=ee,omitempty"`
+}
+
+func TestSessionValidate(t *testing.T) {
+	s := NewSession()
+	for _, d := range []interface{}{testType{}, &testPKType{}, testAliasType{}, testPerson{}} {
+		if err := s.Validate(d); err != nil {
+			t.Errorf("%T: unexpected error: %s", d, err)
+		}
+	}
+
+	err := s.Validate(testInvalidTagsType{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, e := range []string{
+		`field A: unknown option "unique"`,
+		`field B: option "default" needs a value`,
+		`field C: malformed option "=x"`,
+		`field C: option "pk" takes no value`,
+		`duplicate column "a" in fields A, D`,
+	} {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("expected error to contain %q got %q", e, err)
+		}
+	}
+	if strings.Contains(err.Error(), "field E") {
+		t.Errorf("unexpected problem with field E in %q", err)
+	}
+
+	err = s.Validate(testDeterministicType{})
+	if e := `duplicate column "id" in fields TestDupA.ID, TestDupB.ID`; err == nil || !strings.Contains(err.Error(), e) {
+		t.Errorf("expected error to contain %q got %v", e, err)
+	}
+
+	if err := s.Validate(1); !errors.Is(err, ErrInvalidDest) {
+		t.Errorf("expected ErrInvalidDest got %v", err)
+	}
+}
+
+// TestScore is a non-struct sql.Scanner.
+type TestScore int
+
+func (s *TestScore) Scan(src interface{}) error {
+	n, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("invalid score %v", src)
+	}
+	*s = TestScore(n)
+	return nil
+}
+
+type testEmbeddedPtrScannerType struct {
+	Name string `sql:"name"`
+	*TestScore
+	*sql.NullString `sql:"nick"`
+}
+
+func TestScanEmbeddedPointerScanner(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	e := []string{`"Name" as "name"`, `"TestScore"`, `"NullString" as "nick"`}
+	if got := s.Columns(testEmbeddedPtrScannerType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("TestScore", int64(7))
+	rows.addValue("nick", "k")
+
+	var r testEmbeddedPtrScannerType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.TestScore == nil || *r.TestScore != 7 {
+		t.Errorf("expected score 7 got %v", r.TestScore)
+	}
+	if r.NullString == nil || r.NullString.String != "k" {
+		t.Errorf("expected nick k got %v", r.NullString)
+	}
+
+	nulls := testRows{}
+	nulls.addValue("TestScore", nil)
+	nulls.addValue("nick", nil)
+	if err := s.Scan(&r, nulls); err != nil {
+		t.Fatal(err)
+	}
+	if r.TestScore != nil || r.NullString != nil {
+		t.Errorf("expected nil embedded scanners got %+v", r)
+	}
+}
+
+type testDirectionalType struct {
+	ID       int64  `sql:"id,pk"`
+	Password string `sql:"password,readignore"`
+	Created  string `sql:"created,writeignore"`
+	Name     string `sql:"name"`
+}
+
+func TestDirectionalIgnore(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	d := testDirectionalType{1, "secret", "now", "n"}
+
+	e := []string{`"ID" as "id"`, `"Created" as "created"`, `"Name" as "name"`}
+	if got := s.Columns(d); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected select columns %q got %q", e, got)
+	}
+	e = []string{`"id"`, `"password"`, `"name"`}
+	if got := s.WritableColumns(d); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected writable columns %q got %q", e, got)
+	}
+	names, vals := s.InsertColumns(d)
+	if !reflect.DeepEqual(names, e) || !reflect.DeepEqual(vals, []interface{}{int64(1), "secret", "n"}) {
+		t.Errorf("unexpected insert columns %q %v", names, vals)
+	}
+	if set, _ := s.UpdateSet(d); set != `"password" = ?, "name" = ?` {
+		t.Errorf("unexpected set clause %q", set)
+	}
+
+	rows := testRows{}
+	rows.addValue("password", "leaked")
+	rows.addValue("created", "then")
+	var r testDirectionalType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.Password != "" || r.Created != "then" {
+		t.Errorf("expected only created to be scanned got %+v", r)
+	}
+}
+
+// noColumnsRows fails the test if its columns are requested.
+type noColumnsRows struct {
+	testRows
+	t *testing.T
+}
+
+func (r noColumnsRows) Columns() ([]string, error) {
+	r.t.Error("unexpected call to Columns")
+	return r.testRows.Columns()
+}
+
+func TestScanCols(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_c", "c")
+
+	var r testType
+	if err := NewSession().ScanCols(&r, noColumnsRows{rows, t}, rows.columns); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+type TestGeo struct {
+	Lat string `sql:"lat"`
+}
+
+type TestPlace struct {
+	City    string `sql:"city"`
+	TestGeo `sql:"geo,prefix"`
+}
+
+type testSepType struct {
+	Name      string `sql:"name"`
+	TestPlace `sql:"address,prefix,sep=__"`
+}
+
+type testDefaultSepType struct {
+	TestPlace `sql:"home,prefix"`
+}
+
+func TestPrefixSeparator(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+
+	e := []string{`"Name" as "name"`, `"City" as "address__city"`, `"Lat" as "address__geo_lat"`}
+	if got := s.Columns(testSepType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+	e = []string{`"City" as "home_city"`, `"Lat" as "home_geo_lat"`}
+	if got := s.Columns(testDefaultSepType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("address__city", "c")
+	rows.addValue("address__geo_lat", "1.5")
+	s.Strict = true
+	var r testSepType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testSepType{"n", TestPlace{"c", TestGeo{"1.5"}}}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	var r testIntType
+
+	if err := Scan(r, testRows{}); !errors.Is(err, ErrInvalidDest) {
+		t.Errorf("expected ErrInvalidDest got %v", err)
+	}
+
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("extra", "x")
+	if err := StrictScan(&r, rows); !errors.Is(err, ErrUnmappedColumn) {
+		t.Errorf("expected ErrUnmappedColumn got %v", err)
+	}
+
+	rows = testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("name", "m")
+	if err := StrictScan(&r, rows); !errors.Is(err, ErrDuplicateColumn) {
+		t.Errorf("expected ErrDuplicateColumn got %v", err)
+	}
+
+	rows = testRows{}
+	rows.addValue("age", "old")
+	err := Scan(&r, rows)
+	var serr *ScanError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected *ScanError got %v", err)
+	}
+	if serr.Column != "age" || serr.Field != "Age" || serr.Type != reflect.TypeOf(0) {
+		t.Errorf("unexpected scan error %+v", serr)
+	}
+	var nerr *strconv.NumError
+	if !errors.As(err, &nerr) {
+		t.Errorf("expected the driver error to be wrapped got %v", err)
+	}
+
+	var p struct {
+		Age *int64 `sql:"age"`
+	}
+	mem := NewMemRows([]string{"age"}, [][]interface{}{{"old"}})
+	mem.Next()
+	if err := Scan(&p, mem); !errors.As(err, &serr) || serr.Type != reflect.TypeOf(p.Age) {
+		t.Errorf("expected a scan error on a *int64 field got %v", err)
+	}
+}
+
+type TestLevel3 struct {
+	C string `sql:"c"`
+}
+
+type TestLevel2 struct {
+	B string `sql:"b"`
+	TestLevel3
+}
+
+type TestLevel1 struct {
+	A string `sql:"a"`
+	TestLevel2
+}
+
+type testDepthType struct {
+	Name string `sql:"name"`
+	TestLevel1
+}
+
+func TestSessionMaxDepth(t *testing.T) {
+	s := NewSession()
+	s.Qualify = false
+	e := []string{`"Name" as "name"`, `"A" as "a"`, `"B" as "b"`, `"C" as "c"`}
+	if got := s.Columns(testDepthType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+
+	s = NewSession()
+	s.Qualify = false
+	s.MaxDepth = 1
+	e = []string{`"Name" as "name"`, `"A" as "a"`, `"TestLevel2"`}
+	if got := s.Columns(testDepthType{}); !reflect.DeepEqual(got, e) {
+		t.Errorf("expected %q got %q", e, got)
+	}
+}
+
+type testBoolType struct {
+	OK bool `sql:"ok"`
+}
+
+func TestSessionFlexibleBool(t *testing.T) {
+	s := NewSession()
+	s.FlexibleBool = true
+	tests := []struct {
+		v interface{}
+		e bool
+	}{
+		{int64(1), true}, {int64(0), false},
+		{"1", true}, {"0", false},
+		{"TRUE", true}, {"false", false},
+		{[]byte("t"), true}, {"F", false},
+		{"Y", true}, {[]byte("n"), false},
+		{true, true},
+	}
+	for _, tt := range tests {
+		rows := testRows{}
+		rows.addValue("ok", tt.v)
+		r := testBoolType{!tt.e}
+		if err := s.Scan(&r, rows); err != nil {
+			t.Errorf("%#v: unexpected error: %s", tt.v, err)
+		} else if r.OK != tt.e {
+			t.Errorf("%#v: expected %t got %t", tt.v, tt.e, r.OK)
+		}
+	}
+
+	for _, v := range []interface{}{"maybe", int64(2), nil} {
+		rows := testRows{}
+		rows.addValue("ok", v)
+		var r testBoolType
+		if err := s.Scan(&r, rows); err == nil {
+			t.Errorf("%#v: expected error", v)
+		}
+	}
+
+	// Pointer fields are allocated, and left nil on NULL.
+	var p struct {
+		A *bool `sql:"a"`
+		B *bool `sql:"b"`
+	}
+	rows := NewMemRows([]string{"a", "b"}, [][]interface{}{{"y", nil}})
+	rows.Next()
+	if err := s.Scan(&p, rows); err != nil {
+		t.Fatal(err)
+	}
+	if p.A == nil || !*p.A || p.B != nil {
+		t.Errorf("expected a true and a nil b got %v %v", p.A, p.B)
+	}
+}
+
+func TestScanOptions(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_x", "x")
+
+	var sessionLog, callLog testLogger
+	s := NewSession()
+	s.Logger = &sessionLog
+
+	r := testType{FieldC: "stale"}
+	err := s.Scan(&r, rows, Strict(), ResetFields())
+	if !errors.Is(err, ErrUnmappedColumn) {
+		t.Errorf("expected ErrUnmappedColumn; got %v", err)
+	}
+	if r.FieldC != "stale" {
+		t.Errorf("expected a failed scan to leave dest unchanged; got %+v", r)
+	}
+	if err := s.Scan(&r, rows, ResetFields(), WithLogger(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+	if s.Strict {
+		t.Errorf("options must not change the session")
+	}
+
+	r = testType{FieldC: "kept"}
+	if err := s.Scan(&r, rows, WithLogger(&callLog)); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "kept"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+	if len(sessionLog) != 1 || len(callLog) != 1 {
+		t.Errorf("expected one notice per logger; got %q and %q", sessionLog, callLog)
+	}
+
+	var all []testType
+	if err := s.ScanAll(&all, &testResult{columns: rows.columns, rows: [][]interface{}{rows.values}}, Strict()); !errors.Is(err, ErrUnmappedColumn) {
+		t.Errorf("expected ErrUnmappedColumn from ScanAll; got %v", err)
+	}
+}
+
+func TestScanPositional(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("x", "a")
+	rows.addValue("y", "c")
+
+	s := NewSession()
+	s.IncludeUntagged = false
+	var r testType
+	if err := s.ScanPositional(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	rows.addValue("z", "z")
+	if err := s.ScanPositional(&r, rows); err == nil {
+		t.Errorf("expected error for 3 columns and 2 fields")
+	}
+}
+
+func BenchmarkScanPositional(b *testing.B) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_c", "c")
+
+	s := NewSession()
+	s.IncludeUntagged = false
+	for _, positional := range []bool{false, true} {
+		b.Run(fmt.Sprintf("positional=%t", positional), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var r testType
+				var err error
+				if positional {
+					err = s.ScanPositional(&r, rows)
+				} else {
+					err = s.Scan(&r, rows)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReturningColumns(t *testing.T) {
+	s := NewSession()
+	s.Dialect = Postgres
+	if c, e := s.ReturningColumns(&testReadonlyType{}), `"id", "name"`; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c := s.ReturningColumns(1); c != "" {
+		t.Errorf("expected empty list for a non-struct, got %q", c)
+	}
+}
+
+type TestCustomer struct {
+	ID   int    `sql:"id"`
+	Name string `sql:"name"`
+}
+
+type testOrder struct {
+	ID       int          `sql:"id"`
+	Customer TestCustomer `sql:"customer,prefix"`
+}
+
+type testBadOrder struct {
+	ID       int          `sql:"id"`
+	Customer TestCustomer `sql:"customer"`
+}
+
+func TestNamedPrefixField(t *testing.T) {
+	s := NewSession()
+	if c, e := s.ReturningColumns(testOrder{}), `"id", "customer_id", "customer_name"`; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	rows := testRows{}
+	rows.addValue("id", "1")
+	rows.addValue("customer_id", "2")
+	rows.addValue("customer_name", "c")
+	var r testOrder
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testOrder{1, TestCustomer{2, "c"}}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
+
+func TestNamedStructColumn(t *testing.T) {
+	s := NewSession()
+	if c, e := s.ReturningColumns(testBadOrder{}), `"id", "customer"`; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	rows := testRows{}
+	rows.addValue("id", "1")
+	rows.addValue("customer", "c")
+	var r testBadOrder
+	if err := s.Scan(&r, rows); err == nil || !strings.Contains(err.Error(), "not a sql.Scanner") {
+		t.Errorf("expected error for a struct field that is not a scanner; got %v", err)
+	}
+	if err := s.Validate(testBadOrder{}); err == nil {
+		t.Errorf("expected Validate to report the struct field")
+	}
+}
+
+func TestIterate(t *testing.T) {
+	rows := &testResult{
+		columns: []string{"field_a", "field_c"},
+		rows: [][]interface{}{
+			{"a1", "c1"},
+			{"a2", "c2"},
+			{"a3", "c3"},
+		},
+	}
+	stop := errors.New("stop")
+
+	var seen []testType
+	var r testType
+	err := NewSession().Iterate(&r, rows, func(dest interface{}) error {
+		seen = append(seen, *dest.(*testType))
+		if len(seen) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("expected the error of fn; got %v", err)
+	}
+	e := []testType{{FieldA: "a1", FieldC: "c1"}, {FieldA: "a2", FieldC: "c2"}}
+	if !reflect.DeepEqual(seen, e) {
+		t.Errorf("expected %+v got %+v", e, seen)
+	}
+}
+
+// TestMoney is a driver.Valuer, stored as a single column.
+type TestMoney struct {
+	Amount   int64  `sql:"amount"`
+	Currency string `sql:"currency"`
+}
+
+func (m TestMoney) Value() (driver.Value, error) {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency), nil
+}
+
+type testPriced struct {
+	Name string `sql:"name"`
+	TestMoney
+}
+
+func TestValuerField(t *testing.T) {
+	s := NewSession()
+	v := testPriced{"n", TestMoney{100, "EUR"}}
+	if c, e := s.ReturningColumns(v), `"name", "TestMoney"`; c != e {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	e := []interface{}{"n", TestMoney{100, "EUR"}}
+	if vals := s.Values(v); !reflect.DeepEqual(vals, e) {
+		t.Errorf("expected %v got %v", e, vals)
+	}
+}
+
+func TestScanValuerField(t *testing.T) {
+	// The Valuer is a single column, which cannot be scanned as it does not
+	// implement sql.Scanner.
+	rows := testRows{}
+	rows.addValue("name", "n")
+	rows.addValue("TestMoney", "100 EUR")
+	var r testPriced
+	err := NewSession().Scan(&r, rows)
+	if err == nil || !strings.Contains(err.Error(), "not a sql.Scanner") {
+		t.Errorf("expected error scanning in to a driver.Valuer struct; got %v", err)
+	}
+
+	// Without its column the other fields scan as usual.
+	rows = testRows{}
+	rows.addValue("name", "n")
+	if err := NewSession().Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "n" {
+		t.Errorf("expected Name to be scanned, got %+v", r)
+	}
+}
+
+func TestUpsertStmt(t *testing.T) {
+	s := NewSession()
+	s.Dialect = Postgres
+	v := testUpdateType{1, "c", "n", "e"}
+	stmt, args, err := s.UpsertStmt(v, "users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `INSERT INTO users ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "email" = EXCLUDED."email"`
+	if stmt != e {
+		t.Errorf("expected %q got %q", e, stmt)
+	}
+	if e := []interface{}{int64(1), "n", "e"}; !reflect.DeepEqual(args, e) {
+		t.Errorf("expected %v got %v", e, args)
+	}
+
+	stmt, _, _ = s.UpsertStmt(v, "users", []string{"email"})
+	e = `INSERT INTO users ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`
+	if stmt != e {
+		t.Errorf("expected %q got %q", e, stmt)
+	}
+
+	// DO UPDATE needs a conflict target.
+	var nopk struct {
+		Name string `sql:"name"`
+	}
+	if stmt, _, err := s.UpsertStmt(nopk, "users", nil); err == nil {
+		t.Errorf("expected error for an upsert without conflict target got %q", stmt)
+	}
+
+	s.Dialect = MySQL
+	stmt, _, _ = s.UpsertStmt(&v, "users", nil)
+	e = "INSERT INTO users (`id`, `name`, `email`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `email` = VALUES(`email`)"
+	if stmt != e {
+		t.Errorf("expected %q got %q", e, stmt)
+	}
+
+	if _, _, err := s.UpsertStmt(1, "users", nil); !errors.Is(err, ErrInvalidDest) {
+		t.Errorf("expected ErrInvalidDest got %v", err)
+	}
+}
+
+type testUserTotal struct {
+	TestUser `sql:",star"`
+	Total    string `sql:"total"`
+}
+
+func TestStarColumns(t *testing.T) {
+	s := NewSession()
+	e := []string{`"TestUser".*`, `"testUserTotal"."Total" as "total"`}
+	if c := s.Columns(testUserTotal{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	s.Qualify = false
+	e = []string{`*`, `"Total" as "total"`}
+	if c := s.Columns(testUserTotal{}); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+
+	rows := testRows{}
+	rows.addValue("id", "u1")
+	rows.addValue("name", "bob")
+	rows.addValue("total", "3")
+	var r testUserTotal
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testUserTotal{TestUser{"u1", "bob"}, "3"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+	if err := s.Validate(testUserTotal{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestOnCollision(t *testing.T) {
+	var collisions []string
+	s := NewSession()
+	s.OnCollision = func(name string) {
+		// The session may be used from the callback.
+		s.Columns(testType{})
+		collisions = append(collisions, name)
+	}
+	s.Columns(testDeterministicType{})
+	if e := []string{"id", "note"}; !reflect.DeepEqual(collisions, e) {
+		t.Errorf("expected %q got %q", e, collisions)
+	}
+
+	// Resolved types are cached and not reported again.
+	s.Columns(testDeterministicType{})
+	if len(collisions) != 2 {
+		t.Errorf("expected collisions to be reported once; got %q", collisions)
+	}
+}
+
+func TestScanTracked(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_c", "c")
+	rows.addValue("field_x", "x")
+
+	var r testType
+	set, err := NewSession().ScanTracked(&r, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := []string{"field_c"}; !reflect.DeepEqual(set, e) {
+		t.Errorf("expected %q got %q", e, set)
+	}
+	if r.FieldC != "c" {
+		t.Errorf("expected FieldC to be set, got %+v", r)
+	}
+}
+
+func BenchmarkFlatScan(b *testing.B) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_b", "b")
+	rows.addValue("field_c", "c")
+
+	p, err := NewSession().Plan(testType{}, []string{"field_a", "field_b", "field_c"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if !p.flat {
+		b.Fatal("expected a flat plan")
+	}
+	for _, flat := range []bool{false, true} {
+		b.Run(fmt.Sprintf("flat=%t", flat), func(b *testing.B) {
+			p := *p
+			p.flat = flat
+			values := make([]interface{}, len(p.cols))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var r testType
+				if err := p.scan(reflect.ValueOf(&r), rows, values); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFlatTypeInfo(t *testing.T) {
+	s := NewSession()
+	if !s.info(reflect.TypeOf(testType{})).flat {
+		t.Errorf("expected testType to be flat")
+	}
+	if s.info(reflect.TypeOf(testPerson{})).flat {
+		t.Errorf("expected testPerson with embedded structs not to be flat")
+	}
+}
+
+type testHintType struct {
+	Count interface{} `sql:"count,type=int64"`
+	Data  interface{} `sql:"data,type=bytes"`
+	Raw   interface{} `sql:"raw"`
+	Null  interface{} `sql:"null,type=float64"`
+}
+
+func TestScanTypeHint(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("count", "42")
+	rows.addValue("data", "abc")
+	rows.addValue("raw", "42")
+	rows.addValue("null", nil)
+
+	r := testHintType{Null: 1.5}
+	if err := NewSession().Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	e := testHintType{int64(42), []byte("abc"), "42", nil}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %#v got %#v", e, r)
+	}
+
+	rows = testRows{}
+	rows.addValue("count", "x")
+	if err := NewSession().Scan(&r, rows); err == nil {
+		t.Errorf("expected error converting %q to int64", "x")
+	}
+}
+
+func TestTrimStrings(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "  a  ")
+	rows.addValue("field_c", []byte("c   "))
+
+	s := NewSession()
+	s.TrimStrings = true
+	var r testType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "  a", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	s.TrimBoth = true
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "c"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+
+	// Pointer fields are allocated, and left nil on NULL.
+	var p struct {
+		A *string `sql:"a"`
+		B *string `sql:"b"`
+	}
+	rows = testRows{}
+	rows.addValue("a", []byte(" a "))
+	rows.addValue("b", nil)
+	if err := s.Scan(&p, rows); err != nil {
+		t.Fatal(err)
+	}
+	if p.A == nil || *p.A != "a" || p.B != nil {
+		t.Errorf("expected a %q and a nil b got %v %v", "a", p.A, p.B)
+	}
+}
+
+// testReverseScanner stores string column values reversed in dst.
+type testReverseScanner struct {
+	dst reflect.Value
+}
+
+func (s testReverseScanner) Scan(src interface{}) error {
+	r := []rune(src.(string))
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	s.dst.SetString(string(r))
+	return nil
+}
+
+type testNamedScannerType struct {
+	Name string `sql:"name,scanner=reverse"`
+	Kind string `sql:"kind"`
+}
+
+func TestNamedScanner(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("name", "abc")
+	rows.addValue("kind", "abc")
+
+	s := NewSession()
+	var r testNamedScannerType
+	if err := s.Scan(&r, rows); err == nil || !strings.Contains(err.Error(), `unregistered scanner "reverse"`) {
+		t.Errorf("expected error for an unregistered scanner; got %v", err)
+	}
+
+	s.RegisterNamedScanner("reverse", func(dst reflect.Value) sql.Scanner {
+		return &testReverseScanner{dst}
+	})
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testNamedScannerType{"cba", "abc"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+	if err := s.Validate(r); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+type testGoTagType struct {
+	UserID   int64  `sql:"UserID,pk"`
+	FullName string `sql:"FullName"`
+}
+
+func TestColumnNameTransform(t *testing.T) {
+	s := NewSession()
+	s.ColumnNameTransform = SnakeCase
+	v := testGoTagType{1, "n"}
+
+	e := []string{`"testGoTagType"."UserID" as "user_id"`, `"testGoTagType"."FullName" as "full_name"`}
+	if c := s.Columns(v); !reflect.DeepEqual(c, e) {
+		t.Errorf("expected %q got %q", e, c)
+	}
+	if c, _ := s.InsertColumns(v); !reflect.DeepEqual(c, []string{`"user_id"`, `"full_name"`}) {
+		t.Errorf("unexpected insert columns %q", c)
+	}
+	if set, _ := s.UpdateSet(v); set != `"full_name" = ?` {
+		t.Errorf("unexpected SET clause %q", set)
+	}
+	if name, _, _ := s.PrimaryKey(v); name != `"user_id"` {
+		t.Errorf("unexpected primary key %q", name)
+	}
+
+	// Column names given to the session are the transformed ones.
+	if c := s.ColumnsOnly(v, "full_name", "FullName"); !reflect.DeepEqual(c, e[1:]) {
+		t.Errorf("expected %q got %q", e[1:], c)
+	}
+	if c := s.ColumnsExcept(v, "user_id"); !reflect.DeepEqual(c, e[1:]) {
+		t.Errorf("expected %q got %q", e[1:], c)
+	}
+	if set, _ := s.UpdateSet(v, "full_name", "UserID"); set != `"full_name" = ?` {
+		t.Errorf("unexpected SET clause %q", set)
+	}
+
+	// Scanning still matches the tag names.
+	rows := testRows{}
+	rows.addValue("FullName", "n")
+	rows.addValue("full_name", "x")
+	var r testGoTagType
+	if err := s.Scan(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if r.FullName != "n" {
+		t.Errorf("expected FullName to be scanned from its tag name, got %+v", r)
+	}
+
+	// Unless the transformed names are matched too, so that the generated
+	// columns round trip. The tag names still take precedence.
+	s = NewSession()
+	s.ColumnNameTransform = SnakeCase
+	s.MatchTransformedNames = true
+	r = testGoTagType{}
+	mem := NewMemRows([]string{"user_id", "full_name"}, [][]interface{}{{int64(1), "x"}})
+	mem.Next()
+	set, err := s.ScanTracked(&r, mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := (testGoTagType{1, "x"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+	if e := []string{"user_id", "full_name"}; !reflect.DeepEqual(set, e) {
+		t.Errorf("expected tracked columns %q got %q", e, set)
+	}
+
+	rows = testRows{}
+	rows.addValue("full_name", "x")
+	rows.addValue("FullName", "n")
+	r = testGoTagType{}
+	if err := s.Scan(&r, rows, WithLogger(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if r.FullName != "n" {
+		t.Errorf("expected FullName to be scanned from its tag name, got %+v", r)
+	}
+}
+
+func TestUpsertStmtColumnNameTransform(t *testing.T) {
+	s := NewSession()
+	s.ColumnNameTransform = SnakeCase
+	q, _, err := s.UpsertStmt(testGoTagType{1, "n"}, "people", []string{"full_name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(q, `ON CONFLICT ("full_name")`) {
+		t.Errorf("expected the conflict target to be full_name: %s", q)
+	}
+	if strings.Contains(q, `"full_name" = `) {
+		t.Errorf("expected full_name not to be updated: %s", q)
+	}
+}
+
+type testPositionalOrder struct {
+	Zeta  string `sql:"zeta"`
+	Alpha string `sql:"alpha"`
+}
+
+func TestScanPositionalColumnOrder(t *testing.T) {
+	s := NewSession()
+	s.ColumnOrder = Alphabetical
+	if c, e := s.ReturningColumns(testPositionalOrder{}), `"alpha", "zeta"`; c != e {
+		t.Fatalf("expected %q got %q", e, c)
+	}
+
+	rows := testRows{}
+	rows.addValue("alpha", "A")
+	rows.addValue("zeta", "Z")
+	var r testPositionalOrder
+	if err := s.ScanPositional(&r, rows); err != nil {
+		t.Fatal(err)
+	}
+	if e := (testPositionalOrder{Zeta: "Z", Alpha: "A"}); r != e {
+		t.Errorf("expected %+v got %+v", e, r)
+	}
+}
@@ -0,0 +1,25 @@
+package sqlstruct
+
+import "context"
+
+// Shims with the signatures of their sqlx counterparts, to ease migrating
+// code from sqlx. They use the package-level session.
+
+// StructScan scans the current row of rows in to the struct pointed to by
+// dest. It is Scan with sqlx's argument order.
+func StructScan(rows Rows, dest interface{}) error {
+	return std.Scan(dest, rows)
+}
+
+// Get runs query with args on q and scans the first resulting row in to the
+// struct pointed to by dest. If the query returned no rows, sql.ErrNoRows is
+// returned. See Session.Get.
+func Get(q Queryer, dest interface{}, query string, args ...interface{}) error {
+	return std.Get(context.Background(), q, dest, query, args...)
+}
+
+// Select runs query with args on q and scans all resulting rows in to the
+// slice pointed to by dest. See Session.Query.
+func Select(q Queryer, dest interface{}, query string, args ...interface{}) error {
+	return std.Query(context.Background(), q, dest, query, args...)
+}
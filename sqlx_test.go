@@ -0,0 +1,52 @@
+package sqlstruct
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestStructScan(t *testing.T) {
+	rows := testRows{}
+	rows.addValue("field_a", "a")
+	rows.addValue("field_c", "c")
+
+	var r testType
+	if err := StructScan(rows, &r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testType{FieldA: "a", FieldC: "c"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	var r testType
+	if err := Get(db, &r, "types"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e := (testType{"a1", "", "c1"}); r != e {
+		t.Errorf("expected %q got %q", e, r)
+	}
+
+	if err := Get(db, &r, "empty"); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows got %v", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	var r []testType
+	if err := Select(db, &r, "types"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := []testType{{"a1", "", "c1"}, {"a2", "", "c2"}}
+	if !reflect.DeepEqual(r, e) {
+		t.Errorf("expected %q got %q", e, r)
+	}
+}
@@ -0,0 +1,152 @@
+package sqlstruct
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Where returns a condition matching the non-zero fields of d, such as
+// `"col1" = ? AND "col2" = ?`, along with the values of those fields as its
+// arguments. Identifiers and placeholders follow the session's Dialect. If
+// every field is zero, or d is not a struct, the clause is empty.
+func (s *Session) Where(d interface{}) (clause string, args []interface{}) {
+	v, err := structValue(d)
+	if err != nil {
+		return "", nil
+	}
+	var conds []string
+	for _, f := range s.fields(v.Type()) {
+		fv, ok := fieldValue(v, f.index)
+		if !ok || fv.IsZero() {
+			continue
+		}
+		args = append(args, fv.Interface())
+		conds = append(conds, s.quoteColumn(f.name)+" = "+s.Dialect.Placeholder(len(args)))
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// UpdateSet returns an assignment list for the SET clause of an UPDATE
+// statement, such as `"col1" = ?, "col2" = ?`, along with the current values
+// of those fields as its arguments. If columns are given only they are
+// assigned, in that order, and names that are not columns of d are skipped.
+// Otherwise every writable column is assigned (see WritableColumns) except
+// those of fields tagged "pk". Identifiers and placeholders follow the session's
+// Dialect, with placeholders numbered from 1. If d is not a struct the clause
+// is empty.
+func (s *Session) UpdateSet(d interface{}, columns ...string) (setClause string, args []interface{}) {
+	v, err := structValue(d)
+	if err != nil {
+		return "", nil
+	}
+	writes := s.writeFields(v.Type())
+	var fields []field
+	if len(columns) > 0 {
+		for _, name := range columns {
+			if f, ok := s.fieldByColumn(writes, name); ok {
+				fields = append(fields, f)
+			}
+		}
+	} else {
+		for _, f := range writes {
+			if f.writable() && !f.opts.contains("pk") {
+				fields = append(fields, f)
+			}
+		}
+	}
+
+	sets := make([]string, 0, len(fields))
+	for _, f := range fields {
+		var val interface{}
+		if fv, ok := fieldValue(v, f.index); ok {
+			val = fv.Interface()
+		}
+		args = append(args, val)
+		sets = append(sets, s.quoteColumn(f.name)+" = "+s.Dialect.Placeholder(len(args)))
+	}
+	return strings.Join(sets, ", "), args
+}
+
+// SelectStmt returns a SELECT statement for the columns of d from table, such
+// as `SELECT "T"."f1", "T"."f2" FROM T WHERE id = ?`. The column list is the
+// one Columns returns, so it is qualified with the table names of the struct
+// types unless the session's Qualify is unset; table must then name or alias
+// those tables. table and where are inserted verbatim, and the WHERE clause is
+// left out if where is empty. It returns "" if d is not a struct.
+func (s *Session) SelectStmt(d interface{}, table string, where string) string {
+	cols := s.Columns(d)
+	if cols == nil {
+		return ""
+	}
+	stmt := "SELECT " + strings.Join(cols, ", ") + " FROM " + table
+	if where != "" {
+		stmt += " WHERE " + where
+	}
+	return stmt
+}
+
+// UpsertStmt returns an INSERT statement for d in to table that updates the
+// existing row instead if it conflicts with one, along with its arguments.
+// The inserted columns and values are those of InsertColumns. On conflict,
+// every inserted column is updated except those of fields tagged "pk" and
+// the conflictCols, which are column names as generated by the session, i.e.
+// transformed by its ColumnNameTransform if set. For MySQL this is an ON
+// DUPLICATE KEY UPDATE clause, such as
+// "... ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)", and for the other
+// dialects an ON CONFLICT clause on conflictCols, or on the primary key
+// columns if none are given, such as
+// `... ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`. As
+// DO UPDATE requires a conflict target, it is an error for those dialects if
+// there are columns to update but neither conflictCols nor primary key
+// columns. table is inserted verbatim. It returns an error wrapping
+// ErrInvalidDest if d is not a struct.
+func (s *Session) UpsertStmt(d interface{}, table string, conflictCols []string) (stmt string, args []interface{}, err error) {
+	v, err := structValue(d)
+	if err != nil {
+		return "", nil, err
+	}
+	names, args := s.InsertColumns(d)
+	var pks, sets []string
+	for _, f := range s.writeFields(v.Type()) {
+		name := s.quoteColumn(f.name)
+		if f.opts.contains("pk") {
+			pks = append(pks, name)
+			continue
+		}
+		if !containsString(names, name) || containsString(conflictCols, s.columnName(f.name)) {
+			continue
+		}
+		if s.Dialect == MySQL {
+			sets = append(sets, name+" = VALUES("+name+")")
+		} else {
+			sets = append(sets, name+" = EXCLUDED."+name)
+		}
+	}
+
+	stmt = "INSERT INTO " + table + " (" + strings.Join(names, ", ") + ") VALUES (" + PlaceholdersN(len(names), s.Dialect) + ")"
+	if s.Dialect == MySQL {
+		if len(sets) == 0 && len(names) > 0 {
+			// Keep the existing row, as there is nothing to update.
+			sets = append(sets, names[0]+" = "+names[0])
+		}
+		return stmt + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), args, nil
+	}
+	target := pks
+	if len(conflictCols) > 0 {
+		target = make([]string, len(conflictCols))
+		for i, c := range conflictCols {
+			target[i] = s.Dialect.Quote(c)
+		}
+	}
+	stmt += " ON CONFLICT"
+	if len(target) > 0 {
+		stmt += " (" + strings.Join(target, ", ") + ")"
+	}
+	if len(sets) == 0 {
+		return stmt + " DO NOTHING", args, nil
+	}
+	if len(target) == 0 {
+		return "", nil, fmt.Errorf("sqlstruct: upsert of %s needs conflict columns or a pk field", v.Type())
+	}
+	return stmt + " DO UPDATE SET " + strings.Join(sets, ", "), args, nil
+}
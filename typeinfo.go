@@ -45,8 +45,12 @@ func (x byIndex) Less(i, j int) bool {
 	return len(x[i].index) < len(x[j].index)
 }
 
+// tagOptions is the comma-separated list of options following the name in a
+// struct field's tag. Options are flags such as "readonly" or key=value pairs
+// such as "default=active".
 type tagOptions string
 
+// contains reports whether the flag option opt is present.
 func (o tagOptions) contains(opt string) bool {
 	if len(o) == 0 {
 		return false
@@ -66,15 +70,108 @@ func (o tagOptions) contains(opt string) bool {
 	return false
 }
 
+// get returns the value of the first key=value option with the given key.
+// Flag options, as checked by contains, never match. An option "key=" has
+// an empty value.
+func (o tagOptions) get(key string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, key+"=") {
+			return s[len(key)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
+
+// getAll returns the values of all key=value options with the given key, for
+// options that may be repeated such as "alias=a,alias=b".
+func (o tagOptions) getAll(key string) []string {
+	var vals []string
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, key+"=") {
+			vals = append(vals, s[len(key)+1:])
+		}
+		s = next
+	}
+	return vals
+}
+
 // index is a slice of field indices - it specifies parent/current
 // field index
 type field struct {
-	ctx   string // containing struct name, empty for top-level struct
+	ctx   string // name of the struct the column is qualified with
 	name  string
 	fname string // field's name (as found in the struct)
 	tag   bool
 	index []int
-	typ   reflect.Type
+	typ   reflect.Type // type of the field, dereferenced if an unnamed pointer
+	decl  reflect.Type // type of the field as declared
+	opts  tagOptions   // options following the name in the field's tag
+	ctxt  reflect.Type // struct type the column is qualified with
+
+	// prefix is prepended to the column names of the fields of an embedded
+	// struct tagged with the "prefix" option while it is being explored.
+	prefix string
+
+	// path is the dot-separated names of the embedded fields the field is
+	// promoted through, e.g. "Addr" for a field of an embedded Addr struct.
+	path string
+
+	// star is the path of the embedded struct tagged with the "star" option
+	// the field belongs to, if any. Such fields are selected all at once.
+	star string
+}
+
+// writable reports whether the column of f may be written by INSERT or
+// UPDATE statements.
+func (f field) writable() bool {
+	return !f.opts.contains("readonly") && !f.opts.contains("writeignore")
+}
+
+// dottedNames returns the name of the column of f qualified with the path of
+// embedded fields leading to it, as is and with the path in lower case, e.g.
+// "Addr.city" and "addr.city". It returns nil if f is not promoted from an
+// embedded struct.
+func (f field) dottedNames() []string {
+	if f.path == "" {
+		return nil
+	}
+	return []string{f.path + "." + f.name, strings.ToLower(f.path) + "." + f.name}
+}
+
+// FieldInfo describes a struct field mapped to a column.
+type FieldInfo struct {
+	Name   string       // column name
+	GoName string       // name of the field as found in the struct
+	Tagged bool         // whether the column name was given by a tag
+	Type   reflect.Type // field type as declared
+}
+
+// Fields returns the fields of d, a struct or pointer to struct, that map to
+// columns, in the same order as Columns. It returns nil if d is not a struct.
+func (s *Session) Fields(d interface{}) []FieldInfo {
+	v, err := structValue(d)
+	if err != nil {
+		return nil
+	}
+	fields := s.fields(v.Type())
+	infos := make([]FieldInfo, len(fields))
+	for i, f := range fields {
+		infos[i] = FieldInfo{f.name, f.fname, f.tag, f.decl}
+	}
+	return infos
 }
 
 func (f field) String() string {
@@ -83,10 +180,21 @@ func (f field) String() string {
 }
 
 func (f field) ColName() string {
+	return f.colName(true, 0)
+}
+
+// colName is like ColName but quotes identifiers in the style of d and
+// leaves out the "ctx". qualification unless qualify is set.
+func (f field) colName(qualify bool, d Dialect) string {
+	col := d.Quote(f.name)
 	if f.name != f.fname {
-		return fmt.Sprintf(`"%s"."%s" as "%s"`, f.ctx, f.fname, f.name)
+		col = d.Quote(f.fname) + " as " + col
 	}
-	return fmt.Sprintf(`"%s"."%s"`, f.ctx, f.name)
+	if qualify && f.ctx != "" {
+		// Unnamed struct types have no name to qualify with.
+		col = d.Quote(f.ctx) + "." + col
+	}
+	return col
 }
 
 // parseTag splits a struct field's sql tag into its name and
@@ -98,17 +206,144 @@ func parseTag(tag string) (string, tagOptions) {
 	return tag, tagOptions("")
 }
 
-func typeFields(t reflect.Type) []field {
+// typeInfo is the cached field info of a struct type.
+type typeInfo struct {
+	fields []field          // fields read by SELECT statements and scanned
+	sorted []field          // fields sorted by column name
+	names  map[string]field // fields indexed by column name
+	folded map[string]field // fields indexed by lower case column name
+
+	// Fields that may be written, including those tagged "readignore" that
+	// are left out of fields, as is and sorted by column name. Fields tagged
+	// "readonly" or "writeignore" are included and must be checked with
+	// field.writable.
+	writes       []field
+	sortedWrites []field
+
+	// Fields tagged with the "rest" option, which collect the columns not
+	// mapped to other fields instead of being columns themselves.
+	rest []field
+
+	// Fields indexed by the alternative column names set with the alias
+	// option, their dotted names (see field.dottedNames) or their names
+	// transformed by Session.ColumnNameTransform if
+	// Session.MatchTransformedNames is set, as is and in lower case.
+	// Column names take precedence.
+	aliases       map[string]field
+	foldedAliases map[string]field
+
+	// flat is set if every field is a direct field of the struct, with an
+	// index of length 1, so that no embedded struct has to be walked.
+	flat bool
+}
+
+// newTypeInfo returns the type info of the fields all. If transform is not
+// nil, the column names it derives from those of the fields are aliases.
+func newTypeInfo(all []field, transform func(string) string) *typeInfo {
+	fields := make([]field, 0, len(all))
+	writes := make([]field, 0, len(all))
+	var rest []field
+	for _, f := range all {
+		switch {
+		case f.opts.contains("rest"):
+			rest = append(rest, f)
+		case f.opts.contains("readignore"):
+			writes = append(writes, f)
+		default:
+			fields = append(fields, f)
+			writes = append(writes, f)
+		}
+	}
+	info := &typeInfo{
+		fields: fields,
+		writes: writes,
+		names:  make(map[string]field, len(fields)),
+		folded: make(map[string]field, len(fields)),
+		rest:   rest,
+		flat:   true,
+	}
+	for _, f := range all {
+		if len(f.index) != 1 {
+			info.flat = false
+		}
+	}
+	info.sorted = append([]field(nil), fields...)
+	sort.Stable(byName(info.sorted))
+	info.sortedWrites = append([]field(nil), writes...)
+	sort.Stable(byName(info.sortedWrites))
+	for _, f := range fields {
+		info.names[f.name] = f
+		info.folded[strings.ToLower(f.name)] = f
+	}
+	for _, f := range fields {
+		aliases := append(f.opts.getAll("alias"), f.dottedNames()...)
+		if transform != nil {
+			aliases = append(aliases, transform(f.name))
+		}
+		for _, alias := range aliases {
+			if info.aliases == nil {
+				info.aliases = make(map[string]field)
+				info.foldedAliases = make(map[string]field)
+			}
+			if _, ok := info.names[alias]; !ok {
+				info.aliases[alias] = f
+			}
+			if _, ok := info.folded[strings.ToLower(alias)]; !ok {
+				info.foldedAliases[strings.ToLower(alias)] = f
+			}
+		}
+	}
+	return info
+}
+
+// typeOptions controls how typeFields maps struct fields to columns.
+type typeOptions struct {
+	tagNames   []string            // struct tag keys holding column names, by priority
+	nameMapper func(string) string // derives names of untagged fields, may be nil
+
+	// includeUntagged maps fields without a tag to columns named after
+	// them. Untagged embedded structs are explored regardless.
+	includeUntagged bool
+
+	logger Logger // reports skipped fields, may be nil
+
+	// collide is called with the names of the columns dropped because
+	// several fields share them at the same depth, if not nil.
+	collide func(name string)
+
+	// maxDepth is the number of levels of embedded structs explored, or 0
+	// to explore all of them.
+	maxDepth int
+}
+
+// typeFields returns the fields of t that map to columns. Only embedded
+// structs and those tagged "prefix" are explored, each at most once per column
+// name prefix, so recursive types terminate: a struct embedding itself
+// contributes its fields once and a named field pointing to its own type, such
+// as the Next field of a linked list node, is a single opaque column unless
+// tagged "-". So is a struct tagged "prefix" that the field is nested in, as
+// its prefix would otherwise grow at every level.
+func typeFields(t reflect.Type, opts typeOptions) []field {
+	return dominantFields(candidateFields(t, opts), opts.collide)
+}
+
+// candidateFields returns all the fields of t that may map to columns,
+// including those hidden by other fields of the same name.
+func candidateFields(t reflect.Type, opts typeOptions) []field {
 	// Anonymous fields to explore at the current level and the next.
 	current := []field{}
-	next := []field{{typ: t}}
+	next := []field{{typ: t, ctxt: t}}
 
 	// Count of queued names for current level and the next.
 	count := map[reflect.Type]int{}
 	nextCount := map[reflect.Type]int{}
 
-	// Types already visited at an earlier level.
-	visited := map[reflect.Type]bool{}
+	// Types already visited at an earlier level, by column name prefix.
+	type visit struct {
+		typ    reflect.Type
+		prefix string
+	}
+	visited := map[visit]bool{}
 
 	// Fields found.
 	var fields []field
@@ -118,10 +353,10 @@ func typeFields(t reflect.Type) []field {
 		count, nextCount = nextCount, map[reflect.Type]int{}
 
 		for _, f := range current {
-			if visited[f.typ] {
+			if visited[visit{f.typ, f.prefix}] {
 				continue
 			}
-			visited[f.typ] = true
+			visited[visit{f.typ, f.prefix}] = true
 
 			// Scan f.typ for fields to include.
 			for i := 0; i < f.typ.NumField(); i++ {
@@ -130,14 +365,13 @@ func typeFields(t reflect.Type) []field {
 					continue
 				}
 
-				// FIXME(ap): skip fields that have no sql tag
+				// Fields without a tag are skipped unless includeUntagged is set,
 				// to enable to mix structs from various domains (i.e. xml + sql)
-				// maybe skip in sqlstruct.Columns()?
-				tag := sf.Tag.Get("sql")
-				if tag == "-" { // || tag == "" {
+				tag, hasTag := lookupTag(sf.Tag, opts.tagNames)
+				if tag == "-" {
 					continue
 				}
-				name, _ := parseTag(tag)
+				name, tagOpts := parseTag(tag)
 				index := make([]int, len(f.index)+1)
 				copy(index, f.index)
 				index[len(f.index)] = i
@@ -148,13 +382,64 @@ func typeFields(t reflect.Type) []field {
 					ft = ft.Elem()
 				}
 
-				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				// Record embedded or named struct whose column names are
+				// prefixed with name and a separator to explore in next
+				// round. Its columns are qualified with its own name, as it
+				// usually stands for a joined table.
+				// Structs nested too deeply are single columns, as are
+				// those holding a value of their own for the driver.
+				opaque := reflect.PtrTo(ft).Implements(scannerType) ||
+					reflect.PtrTo(ft).Implements(valuerType) ||
+					opts.maxDepth > 0 && len(index) > opts.maxDepth ||
+					tagOpts.contains("prefix") && nestedIn(t, index, ft)
+
+				if ft.Kind() == reflect.Struct && tagOpts.contains("prefix") && !opaque {
+					prefix := f.prefix + name + prefixSep(name, tagOpts)
+					path := joinPath(f.path, sf.Name)
+					star := f.star
+					if star == "" && tagOpts.contains("star") {
+						star = path
+					}
+					next = append(next, field{name: ft.Name(), index: index, typ: ft, ctxt: ft, prefix: prefix, path: path, star: star})
+					continue
+				}
+
+				// Record found field and index sequence. Embedded types that
+				// are not structs or implement sql.Scanner or driver.Valuer,
+				// possibly through a pointer, are single columns named after
+				// the type.
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct || opaque {
+					if !hasTag && !opts.includeUntagged {
+						continue
+					}
+					if !scannable(ft) {
+						// No driver value can be stored in such a field.
+						if hasTag && opts.logger != nil {
+							opts.logger.Printf("sqlstruct: skipping field %s.%s of unsupported type %s", t, sf.Name, sf.Type)
+						}
+						continue
+					}
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
+						if opts.nameMapper != nil {
+							name = opts.nameMapper(name)
+						}
 					}
-					fields = append(fields, field{f.typ.Name(), name, sf.Name, tagged, index, ft})
+					name = f.prefix + name
+					fields = append(fields, field{
+						ctx:   f.ctxt.Name(),
+						name:  name,
+						fname: sf.Name,
+						tag:   tagged,
+						index: index,
+						typ:   ft,
+						decl:  sf.Type,
+						opts:  tagOpts,
+						ctxt:  f.ctxt,
+						path:  f.path,
+						star:  f.star,
+					})
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
 						// so that the annihilation code will see a duplicate.
@@ -165,29 +450,69 @@ func typeFields(t reflect.Type) []field {
 					continue
 				}
 
-				// Record new anonymous struct to explore in next round.
+				// Record new anonymous struct to explore in next round. Its
+				// fields are flattened in to the embedding struct and so
+				// qualified with the same name, unless it is tagged with the
+				// "star" option: it then stands for a whole joined table and
+				// is qualified with its own name.
 				nextCount[ft]++
 				if nextCount[ft] == 1 {
-					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+					path := joinPath(f.path, sf.Name)
+					ctxt, star := f.ctxt, f.star
+					if star == "" && tagOpts.contains("star") {
+						ctxt, star = ft, path
+					}
+					next = append(next, field{name: ft.Name(), index: index, typ: ft, ctxt: ctxt, prefix: f.prefix, path: path, star: star})
 				}
 			}
 		}
 	}
 
+	return fields
+}
+
+// nestedIn reports whether the field of t at index is nested in a struct of
+// type ft, t itself included.
+func nestedIn(t reflect.Type, index []int, ft reflect.Type) bool {
+	for _, i := range index[:len(index)-1] {
+		if t == ft {
+			return true
+		}
+		t = t.Field(i).Type
+		if t.Name() == "" && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	return t == ft
+}
+
+// dominantFields returns the fields that are not hidden by another field of
+// the same name, in index order. If collide is not nil it is called with the
+// names that no field dominates, which are dropped.
+func dominantFields(fields []field, collide func(name string)) []field {
 	sort.Sort(byName(fields))
 
-	// Remove fields with annihilating name collisions
-	// and also fields shadowed by fields with explicit JSON tags.
-	name := ""
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with sql tags are promoted. The fields are sorted in
+	// primary order of name, secondary order of field index length and
+	// tagged fields first, so each run of fields with the same name starts
+	// with its dominant field, if any.
 	out := fields[:0]
-	for _, f := range fields {
-		if f.name != name {
-			name = f.name
-			out = append(out, f)
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
 			continue
 		}
-		if n := len(out); n > 0 && out[n-1].name == name && (!out[n-1].tag || f.tag) {
-			out = out[:n-1]
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		} else if collide != nil {
+			collide(fi.name)
 		}
 	}
 	fields = out
@@ -196,3 +521,182 @@ func typeFields(t reflect.Type) []field {
 
 	return fields
 }
+
+// dominantField looks through the fields, all of which are known to have the
+// same name, to find the single field that dominates the others using Go's
+// embedding rules, modified by the presence of tags. If there are multiple
+// top-level fields, the boolean will be false: this condition is an error in
+// Go and we skip all the fields.
+func dominantField(fields []field) (field, bool) {
+	// The fields are sorted in increasing index-length order, then by
+	// presence of tag. That means that the first field is the dominant one.
+	// We need only check for error cases: two fields at top level, either
+	// both tagged or neither tagged.
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tag == fields[1].tag {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// prefixSep returns the separator between the column name prefix name of an
+// embedded struct tagged with the "prefix" option and the names of its
+// columns. It is set with the "sep" option and defaults to "_". No separator
+// is added if name already ends with it, as in "user_,prefix".
+func prefixSep(name string, opts tagOptions) string {
+	sep, ok := opts.get("sep")
+	if !ok {
+		sep = "_"
+	}
+	if strings.HasSuffix(name, sep) {
+		return ""
+	}
+	return sep
+}
+
+// lookupTag returns the value of the first of keys with a non-empty tag, or
+// of the first key present if all are empty.
+func lookupTag(tag reflect.StructTag, keys []string) (string, bool) {
+	var first string
+	found := false
+	for _, key := range keys {
+		v, ok := tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		if v != "" {
+			return v, true
+		}
+		if !found {
+			first, found = v, true
+		}
+	}
+	return first, found
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// scannable reports whether a column value may be scanned in to a field of
+// type t.
+func scannable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return reflect.PtrTo(t).Implements(scannerType)
+	}
+	return true
+}
+
+// unscannableStruct reports whether f is a struct mapped to a single column
+// that no column value can be scanned in to, because it implements neither
+// sql.Scanner nor encoding.TextUnmarshaler, is not a time.Time and has none
+// of the "json", "time" and "scanner" options. Such fields usually lack the
+// prefix option to map their own fields.
+func unscannableStruct(f *field) bool {
+	if f.typ.Kind() != reflect.Struct || f.typ == timeType || f.opts.contains("json") {
+		return false
+	}
+	if _, ok := f.opts.get("time"); ok {
+		return false
+	}
+	if _, ok := f.opts.get("scanner"); ok {
+		return false
+	}
+	pt := reflect.PtrTo(f.typ)
+	return !pt.Implements(scannerType) && !pt.Implements(textUnmarshalerType)
+}
+
+// Tag options understood by the package, as flags and as key=value pairs.
+var (
+	flagOptions  = []string{"omitempty", "readonly", "pk", "prefix", "json", "rest", "readignore", "writeignore", "star"}
+	valueOptions = []string{"default", "time", "alias", "nullsrc", "sep", "type", "scanner"}
+)
+
+// Validate checks the tags of the struct type of d, a struct or pointer to
+// struct, for mistakes: options the package does not know, malformed
+// key=value options, struct fields mapped to a single column they cannot be
+// scanned from and column names shared by several fields, which hide each
+// other. It returns an error listing every problem found, or nil. It is
+// meant to be run on all models by a test or at startup.
+func (s *Session) Validate(d interface{}) error {
+	v, err := structValue(d)
+	if err != nil {
+		return err
+	}
+	t := v.Type()
+	fields := candidateFields(t, s.typeOptions(s.tagName()))
+
+	var problems []string
+	for _, f := range fields {
+		for _, opt := range strings.Split(string(f.opts), ",") {
+			if p := checkOption(opt); p != "" {
+				problems = append(problems, fmt.Sprintf("field %s: %s", f.fname, p))
+			}
+		}
+		s.mu.RLock()
+		_, conv := s.converters[f.typ]
+		s.mu.RUnlock()
+		if f.tag && !conv && unscannableStruct(&f) {
+			problems = append(problems, fmt.Sprintf("field %s: struct type %s is not a sql.Scanner and has no prefix option", f.fname, f.typ))
+		}
+	}
+
+	kept := make(map[string]bool)
+	for _, f := range dominantFields(append([]field(nil), fields...), nil) {
+		kept[f.name] = true
+	}
+	hidden := make(map[string][]string)
+	var names []string
+	for _, f := range fields {
+		if kept[f.name] {
+			continue
+		}
+		if hidden[f.name] == nil {
+			names = append(names, f.name)
+		}
+		// Fields embedded twice at the same depth are listed twice.
+		fname := joinPath(f.path, f.fname)
+		if fn := hidden[f.name]; len(fn) == 0 || fn[len(fn)-1] != fname {
+			hidden[f.name] = append(fn, fname)
+		}
+	}
+	for _, name := range names {
+		problems = append(problems, fmt.Sprintf("duplicate column %q in fields %s", name, strings.Join(hidden[name], ", ")))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("sqlstruct: invalid tags in %s: %s", t, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkOption returns the problem with the tag option opt, or "" if it is
+// valid.
+func checkOption(opt string) string {
+	if opt == "" {
+		return ""
+	}
+	key := opt
+	if i := strings.Index(opt, "="); i >= 0 {
+		key = opt[:i]
+		switch {
+		case key == "":
+			return fmt.Sprintf("malformed option %q", opt)
+		case containsString(valueOptions, key):
+			return ""
+		case containsString(flagOptions, key):
+			return fmt.Sprintf("option %q takes no value", key)
+		}
+		return fmt.Sprintf("unknown option %q", key)
+	}
+	switch {
+	case containsString(flagOptions, key):
+		return ""
+	case containsString(valueOptions, key):
+		return fmt.Sprintf("option %q needs a value", key)
+	}
+	return fmt.Sprintf("unknown option %q", key)
+}